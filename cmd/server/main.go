@@ -9,11 +9,14 @@ import (
 	"github.com/gofiber/fiber/v2/middleware/compress"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/logger"
+	"github.com/gofiber/fiber/v2/middleware/recover"
 
 	"github.com/pavelc4/ytdpl-api-go/config"
+	"github.com/pavelc4/ytdpl-api-go/internal/cache"
 	handlers "github.com/pavelc4/ytdpl-api-go/internal/handler"
 	"github.com/pavelc4/ytdpl-api-go/internal/routes"
 	"github.com/pavelc4/ytdpl-api-go/internal/services"
+	"github.com/pavelc4/ytdpl-api-go/internal/store"
 )
 
 func main() {
@@ -29,30 +32,62 @@ func main() {
 		log.Printf("  No cookie configured (age-restricted videos may fail)")
 	}
 
-	r2Service, err := services.NewR2Service(cfg.R2Config)
+	storage, err := services.NewStorage(cfg)
 	if err != nil {
-		log.Printf("Warning: Failed to initialize R2 service: %v", err)
+		log.Printf("Warning: Failed to initialize %s storage: %v", cfg.StorageBackend, err)
+	} else if cfg.RetentionDays <= 0 {
+		log.Printf(" Storage backend: %s (cleanup disabled)", cfg.StorageBackend)
 	} else {
+		log.Printf(" Storage backend: %s", cfg.StorageBackend)
 		go func() {
 			log.Println(" Starting background cleanup task (every 24h)")
 			ticker := time.NewTicker(24 * time.Hour)
 			defer ticker.Stop()
 
-			if err := r2Service.CleanupOldFiles(context.Background(), 7); err != nil {
+			if err := services.CleanupOldFiles(context.Background(), storage, cfg.RetentionDays); err != nil {
 				log.Printf(" Initial cleanup failed: %v", err)
 			}
 
 			for range ticker.C {
-				if err := r2Service.CleanupOldFiles(context.Background(), 7); err != nil {
+				if err := services.CleanupOldFiles(context.Background(), storage, cfg.RetentionDays); err != nil {
 					log.Printf(" Scheduled cleanup failed: %v", err)
 				}
 			}
 		}()
 	}
 
-	ytdlpService := services.NewYTDLPService(cfg.CookiePath)
+	jobStore, err := store.New(cfg.StorePath)
+	if err != nil {
+		log.Fatalf("Failed to open job store: %v", err)
+	}
+	defer jobStore.Close()
+
+	if cfg.StorePath != "" {
+		log.Printf(" Job store: %s", cfg.StorePath)
+	} else {
+		log.Printf("  No job store configured (jobs are lost on restart)")
+	}
+
+	appCache, err := cache.New(cfg.Cache)
+	if err != nil {
+		log.Fatalf("Failed to initialize %s cache: %v", cfg.Cache.Backend, err)
+	}
+	defer appCache.Close()
+	log.Printf(" Cache backend: %s", cfg.Cache.Backend)
+
+	ytdlpService := services.NewYTDLPService(cfg.CookiePath, services.NewWorkerPool(cfg.YTDLPWorkers, cfg.YTDLPWorkers), appCache)
+	jobManager := services.NewJobManager(ytdlpService, storage, services.NewWorkerPool(cfg.MergeWorkers, cfg.MergeQueueSize), jobStore, appCache, services.JobOptions{
+		StreamUploads: cfg.StreamUploads,
+		ContentHash:   cfg.ContentHash,
+	})
+	if err := jobManager.Resume(); err != nil {
+		log.Printf("Warning: Failed to resume jobs: %v", err)
+	}
 
-	videoHandler := handlers.NewVideoHandler(ytdlpService, r2Service)
+	videoHandler := handlers.NewVideoHandler(ytdlpService, jobManager)
+	jobHandler := handlers.NewJobHandler(jobManager)
+	batchHandler := handlers.NewBatchHandler(services.NewBatchManager(ytdlpService, jobManager, storage))
+	fileHandler := handlers.NewFileHandler(storage)
 	healthHandler := handlers.NewHealthHandler()
 
 	app := fiber.New(fiber.Config{
@@ -61,6 +96,7 @@ func main() {
 		BodyLimit:    10 * 1024 * 1024,
 	})
 
+	app.Use(recover.New())
 	app.Use(logger.New(logger.Config{
 		Format: "[${time}] ${status} - ${method} ${path} (${latency})\n",
 	}))
@@ -69,10 +105,10 @@ func main() {
 	}))
 	app.Use(cors.New(cors.Config{
 		AllowOrigins: "*",
-		AllowMethods: "GET,POST,OPTIONS",
+		AllowMethods: "GET,POST,DELETE,OPTIONS",
 	}))
 
-	routes.SetupRoutes(app, cfg, videoHandler, healthHandler)
+	routes.SetupRoutes(app, cfg, videoHandler, jobHandler, batchHandler, fileHandler, healthHandler)
 
 	log.Printf(" Server starting on port %s", cfg.Port)
 	log.Fatal(app.Listen(":" + cfg.Port))
@@ -3,6 +3,8 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -11,7 +13,28 @@ type Config struct {
 	Port       string
 	CookiePath string
 	APIVersion string
-	R2Config   R2Config
+
+	// StorageBackend selects where merged files are stored: r2 (or s3),
+	// local or memory.
+	StorageBackend string
+	R2Config       R2Config
+	LocalStorage   LocalStorageConfig
+
+	// YTDLPWorkers bounds how many yt-dlp processes may run at once.
+	YTDLPWorkers int
+	// MergeWorkers bounds how many merge jobs are processed at once.
+	MergeWorkers   int
+	MergeQueueSize int
+	// StreamUploads pipes merged output straight into storage instead of a
+	// temp file when the container format allows it.
+	StreamUploads bool
+	// ContentHash stores a SHA-256 of every uploaded file in its metadata.
+	ContentHash bool
+	// RetentionDays is how long stored files are kept; 0 disables cleanup.
+	RetentionDays int
+	// StorePath is the BoltDB file used to persist jobs; empty keeps them in memory.
+	StorePath string
+	Cache     CacheConfig
 }
 
 type R2Config struct {
@@ -21,17 +44,52 @@ type R2Config struct {
 	BucketName      string
 	Endpoint        string
 	PublicURL       string
+	// Private keeps the bucket private: uploads return presigned GET URLs
+	// valid for PresignTTL instead of PublicURL links.
+	Private    bool
+	PresignTTL time.Duration
+	// PartSizeMB is the multipart chunk size for streamed uploads, which is
+	// also the upload's memory footprint. R2 requires at least 5.
+	PartSizeMB int
+}
+
+type CacheConfig struct {
+	// Backend selects where extractions and upload results are cached:
+	// memory, redis, or tiered (a short-lived in-memory L1 in front of Redis).
+	Backend  string
+	RedisURL string
+	// Prefix namespaces every Redis key.
+	Prefix string
+	// L1TTL caps how long the tiered backend keeps a value in memory.
+	L1TTL time.Duration
 }
 
+type LocalStorageConfig struct {
+	Dir string
+	// BaseURL is the externally reachable address of this API, used to
+	// build links to files it serves.
+	BaseURL string
+	// SigningKey signs file links. It is required by the local backend.
+	SigningKey string
+	LinkTTL    time.Duration
+}
+
+// signingKeyPlaceholder is the FILE_SIGNING_KEY older .env.example files
+// shipped with; it is as good as no key.
+const signingKeyPlaceholder = "change_me"
+
 func Load() *Config {
 	if err := godotenv.Load(); err != nil {
 		log.Println(" No .env file found, using environment variables")
 	}
 
-	return &Config{
-		Port:       getEnv("PORT", "5000"),
-		CookiePath: getEnv("COOKIE_PATH", "./cookies/youtube.txt"),
-		APIVersion: getEnv("API_VERSION", "v1"),
+	port := getEnv("PORT", "5000")
+
+	cfg := &Config{
+		Port:           port,
+		CookiePath:     getEnv("COOKIE_PATH", "./cookies/youtube.txt"),
+		APIVersion:     getEnv("API_VERSION", "v1"),
+		StorageBackend: getEnv("STORAGE_BACKEND", "r2"),
 		R2Config: R2Config{
 			AccountID:       getEnv("R2_ACCOUNT_ID", ""),
 			AccessKeyID:     getEnv("R2_ACCESS_KEY_ID", ""),
@@ -39,8 +97,38 @@ func Load() *Config {
 			BucketName:      getEnv("R2_BUCKET_NAME", ""),
 			Endpoint:        getEnv("R2_ENDPOINT", ""),
 			PublicURL:       getEnv("R2_PUBLIC_URL", ""),
+			Private:         getEnvBool("R2_PRIVATE", false),
+			PresignTTL:      getEnvDuration("R2_PRESIGN_TTL", 1*time.Hour),
+			PartSizeMB:      getEnvInt("R2_PART_SIZE_MB", 8),
+		},
+		LocalStorage: LocalStorageConfig{
+			Dir:        getEnv("LOCAL_STORAGE_DIR", "./data/files"),
+			BaseURL:    getEnv("PUBLIC_BASE_URL", "http://localhost:"+port),
+			SigningKey: getEnv("FILE_SIGNING_KEY", ""),
+			LinkTTL:    getEnvDuration("FILE_LINK_TTL", 24*time.Hour),
+		},
+		YTDLPWorkers:   getEnvInt("YTDLP_WORKERS", 10),
+		MergeWorkers:   getEnvInt("MERGE_WORKERS", 2),
+		MergeQueueSize: getEnvInt("MERGE_QUEUE_SIZE", 100),
+		StreamUploads:  getEnvBool("STREAM_UPLOADS", false),
+		ContentHash:    getEnvBool("DEDUP_CONTENT_HASH", false),
+		RetentionDays:  getEnvInt("RETENTION_DAYS", 7),
+		StorePath:      getEnv("STORE_PATH", ""),
+		Cache: CacheConfig{
+			Backend:  getEnv("CACHE_BACKEND", "memory"),
+			RedisURL: getEnv("REDIS_URL", "redis://localhost:6379/0"),
+			Prefix:   getEnv("CACHE_PREFIX", "ytdpl:"),
+			L1TTL:    getEnvDuration("CACHE_L1_TTL", 1*time.Minute),
 		},
 	}
+
+	if cfg.StorageBackend == "local" {
+		if key := cfg.LocalStorage.SigningKey; key == "" || key == signingKeyPlaceholder {
+			log.Fatal(" FILE_SIGNING_KEY must be set to a secret value when STORAGE_BACKEND=local")
+		}
+	}
+
+	return cfg
 }
 
 func getEnv(key, defaultValue string) string {
@@ -49,3 +137,33 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func getEnvInt(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil {
+			return parsed
+		}
+		log.Printf(" Invalid value for %s: %q, using default %d", key, value, defaultValue)
+	}
+	return defaultValue
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := time.ParseDuration(value); err == nil {
+			return parsed
+		}
+		log.Printf(" Invalid value for %s: %q, using default %s", key, value, defaultValue)
+	}
+	return defaultValue
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := strconv.ParseBool(value); err == nil {
+			return parsed
+		}
+		log.Printf(" Invalid value for %s: %q, using default %t", key, value, defaultValue)
+	}
+	return defaultValue
+}
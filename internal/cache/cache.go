@@ -0,0 +1,44 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/pavelc4/ytdpl-api-go/config"
+)
+
+// ErrMiss is returned by Get when key is missing or expired.
+var ErrMiss = errors.New("cache miss")
+
+// Cache stores JSON-serialized values under string keys. Values round-trip
+// through JSON in every backend, so a value read back is never shared with
+// the caller that stored it.
+type Cache interface {
+	// Get decodes the value stored under key into dst.
+	Get(ctx context.Context, key string, dst any) error
+	// Set stores value under key for ttl; a ttl of 0 never expires.
+	Set(ctx context.Context, key string, value any, ttl time.Duration) error
+	Delete(ctx context.Context, key string) error
+	Close() error
+}
+
+// New creates the cache selected by cfg.Backend: memory, redis, or tiered
+// (an in-memory L1 in front of a shared Redis L2).
+func New(cfg config.CacheConfig) (Cache, error) {
+	switch cfg.Backend {
+	case "", "memory":
+		return NewMemoryCache(), nil
+	case "redis":
+		return NewRedisCache(cfg.RedisURL, cfg.Prefix)
+	case "tiered":
+		l2, err := NewRedisCache(cfg.RedisURL, cfg.Prefix)
+		if err != nil {
+			return nil, err
+		}
+		return NewTieredCache(NewMemoryCache(), l2, cfg.L1TTL), nil
+	default:
+		return nil, fmt.Errorf("unknown cache backend %q", cfg.Backend)
+	}
+}
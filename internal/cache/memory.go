@@ -0,0 +1,49 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	gocache "github.com/patrickmn/go-cache"
+)
+
+// MemoryCache keeps values in process memory, so every replica has its own.
+type MemoryCache struct {
+	items *gocache.Cache
+}
+
+func NewMemoryCache() *MemoryCache {
+	return &MemoryCache{
+		items: gocache.New(15*time.Minute, 30*time.Minute),
+	}
+}
+
+func (c *MemoryCache) Get(_ context.Context, key string, dst any) error {
+	data, found := c.items.Get(key)
+	if !found {
+		return ErrMiss
+	}
+	return json.Unmarshal(data.([]byte), dst)
+}
+
+func (c *MemoryCache) Set(_ context.Context, key string, value any, ttl time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	if ttl <= 0 {
+		ttl = gocache.NoExpiration
+	}
+	c.items.Set(key, data, ttl)
+	return nil
+}
+
+func (c *MemoryCache) Delete(_ context.Context, key string) error {
+	c.items.Delete(key)
+	return nil
+}
+
+func (c *MemoryCache) Close() error {
+	return nil
+}
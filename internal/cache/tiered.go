@@ -0,0 +1,55 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// TieredCache serves reads from a local L1 and falls back to a shared L2.
+// Values are kept in L1 for at most l1TTL, which bounds how long a replica
+// can serve a value another replica has since replaced or deleted.
+type TieredCache struct {
+	l1    Cache
+	l2    Cache
+	l1TTL time.Duration
+}
+
+func NewTieredCache(l1, l2 Cache, l1TTL time.Duration) *TieredCache {
+	if l1TTL <= 0 {
+		l1TTL = time.Minute
+	}
+	return &TieredCache{l1: l1, l2: l2, l1TTL: l1TTL}
+}
+
+func (c *TieredCache) Get(ctx context.Context, key string, dst any) error {
+	if err := c.l1.Get(ctx, key, dst); err == nil {
+		return nil
+	}
+
+	if err := c.l2.Get(ctx, key, dst); err != nil {
+		return err
+	}
+	// Best effort: a failed L1 write only costs another L2 read.
+	_ = c.l1.Set(ctx, key, dst, c.l1TTL)
+	return nil
+}
+
+func (c *TieredCache) Set(ctx context.Context, key string, value any, ttl time.Duration) error {
+	l1TTL := c.l1TTL
+	if ttl > 0 && ttl < l1TTL {
+		l1TTL = ttl
+	}
+	if err := c.l1.Set(ctx, key, value, l1TTL); err != nil {
+		return err
+	}
+	return c.l2.Set(ctx, key, value, ttl)
+}
+
+func (c *TieredCache) Delete(ctx context.Context, key string) error {
+	return errors.Join(c.l1.Delete(ctx, key), c.l2.Delete(ctx, key))
+}
+
+func (c *TieredCache) Close() error {
+	return errors.Join(c.l1.Close(), c.l2.Close())
+}
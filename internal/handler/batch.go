@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/pavelc4/ytdpl-api-go/internal/models"
+	"github.com/pavelc4/ytdpl-api-go/internal/services"
+)
+
+type BatchHandler struct {
+	batchManager *services.BatchManager
+}
+
+func NewBatchHandler(batchManager *services.BatchManager) *BatchHandler {
+	return &BatchHandler{
+		batchManager: batchManager,
+	}
+}
+
+func (h *BatchHandler) Create(c *fiber.Ctx) error {
+	var req models.BatchRequest
+	if err := c.BodyParser(&req); err != nil {
+		response := models.ErrorResponse(
+			"INVALID_INPUT",
+			"Invalid request body",
+			err.Error(),
+		)
+		return c.Status(fiber.StatusBadRequest).JSON(response)
+	}
+
+	if !isValidURL(req.URL) {
+		response := models.ErrorResponse(
+			"INVALID_INPUT",
+			"Invalid URL format",
+			"Please provide a valid playlist or channel URL",
+		)
+		return c.Status(fiber.StatusBadRequest).JSON(response)
+	}
+
+	batch, err := h.batchManager.Submit(req)
+	if err != nil {
+		return submitErrorResponse(c, err)
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(batchResponse(batch))
+}
+
+func (h *BatchHandler) Get(c *fiber.Ctx) error {
+	batch, err := h.batchManager.Get(c.Params("id"))
+	if err != nil {
+		return batchErrorResponse(c, err)
+	}
+
+	return c.JSON(batchResponse(batch))
+}
+
+func (h *BatchHandler) Cancel(c *fiber.Ctx) error {
+	batch, err := h.batchManager.Cancel(c.Params("id"))
+	if err != nil {
+		return batchErrorResponse(c, err)
+	}
+
+	return c.JSON(batchResponse(batch))
+}
+
+func batchResponse(batch *models.Batch) models.Response {
+	response := models.SuccessResponse(batch)
+	response.Meta = &models.Meta{
+		Timestamp: time.Now().Unix(),
+		Version:   "1.0",
+	}
+	return response
+}
+
+func batchErrorResponse(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrBatchNotFound):
+		response := models.ErrorResponse(
+			"NOT_FOUND",
+			"Batch not found",
+			err.Error(),
+		)
+		return c.Status(fiber.StatusNotFound).JSON(response)
+	case errors.Is(err, services.ErrBatchFinished):
+		response := models.ErrorResponse(
+			"CONFLICT",
+			"Batch already finished",
+			err.Error(),
+		)
+		return c.Status(fiber.StatusConflict).JSON(response)
+	default:
+		response := models.ErrorResponse(
+			"INTERNAL_ERROR",
+			"Failed to process batch request",
+			err.Error(),
+		)
+		return c.Status(fiber.StatusInternalServerError).JSON(response)
+	}
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/pavelc4/ytdpl-api-go/internal/models"
+	"github.com/pavelc4/ytdpl-api-go/internal/services"
+)
+
+const maxLinkTTL = 7 * 24 * time.Hour
+
+// FileHandler mints download links for stored objects and, with the local
+// storage backend, serves the files themselves.
+type FileHandler struct {
+	storage services.Storage
+	local   *services.LocalStorage
+}
+
+func NewFileHandler(storage services.Storage) *FileHandler {
+	local, _ := storage.(*services.LocalStorage)
+	return &FileHandler{
+		storage: storage,
+		local:   local,
+	}
+}
+
+// ServesFiles reports whether Serve can be routed, i.e. whether the storage
+// backend is the local filesystem.
+func (h *FileHandler) ServesFiles() bool {
+	return h.local != nil
+}
+
+// Link mints a fresh download link for an existing object, for clients whose
+// previous link has expired. The optional ttl query parameter is in seconds;
+// 0, like leaving it out, selects the backend's default lifetime.
+func (h *FileHandler) Link(c *fiber.Ctx) error {
+	if h.storage == nil {
+		response := models.ErrorResponse(
+			"SERVICE_UNAVAILABLE",
+			"Storage not configured",
+			"Storage backend is missing or misconfigured",
+		)
+		return c.Status(fiber.StatusServiceUnavailable).JSON(response)
+	}
+
+	key, err := url.PathUnescape(c.Params("+"))
+	if err != nil || !services.IsMediaKey(key) {
+		response := models.ErrorResponse(
+			"INVALID_INPUT",
+			"Invalid object key",
+			"Key must refer to a merged video or audio file",
+		)
+		return c.Status(fiber.StatusBadRequest).JSON(response)
+	}
+
+	ttl := time.Duration(c.QueryInt("ttl", 0)) * time.Second
+	if ttl < 0 || ttl > maxLinkTTL {
+		response := models.ErrorResponse(
+			"INVALID_INPUT",
+			"Invalid ttl",
+			fmt.Sprintf("ttl must be between 1 and %d seconds, or 0 for the default", int(maxLinkTTL.Seconds())),
+		)
+		return c.Status(fiber.StatusBadRequest).JSON(response)
+	}
+
+	if _, err := h.storage.Stat(c.Context(), key); err != nil {
+		if errors.Is(err, services.ErrObjectNotFound) {
+			response := models.ErrorResponse(
+				"NOT_FOUND",
+				"File not found",
+				key,
+			)
+			return c.Status(fiber.StatusNotFound).JSON(response)
+		}
+		response := models.ErrorResponse(
+			"STORAGE_ERROR",
+			"Failed to look up file",
+			err.Error(),
+		)
+		return c.Status(fiber.StatusInternalServerError).JSON(response)
+	}
+
+	link, err := h.storage.PresignGet(c.Context(), key, ttl)
+	if err != nil {
+		response := models.ErrorResponse(
+			"STORAGE_ERROR",
+			"Failed to create download link",
+			err.Error(),
+		)
+		return c.Status(fiber.StatusInternalServerError).JSON(response)
+	}
+
+	response := models.SuccessResponse(map[string]string{
+		"key": key,
+		"url": link,
+	})
+	response.Meta = &models.Meta{
+		Timestamp: time.Now().Unix(),
+		Version:   "1.0",
+	}
+
+	return c.JSON(response)
+}
+
+// Serve sends a stored file when the link's signature and expiry check out.
+// Range requests are honoured so media players can seek.
+func (h *FileHandler) Serve(c *fiber.Ctx) error {
+	key := c.Params("*")
+
+	if err := h.local.Verify(key, c.Query("expires"), c.Query("signature")); err != nil {
+		message := "Invalid download link"
+		if errors.Is(err, services.ErrLinkExpired) {
+			message = "Download link has expired"
+		}
+		response := models.ErrorResponse(
+			"FORBIDDEN",
+			message,
+			err.Error(),
+		)
+		return c.Status(fiber.StatusForbidden).JSON(response)
+	}
+
+	path, err := h.local.Path(key)
+	if err == nil {
+		_, err = os.Stat(path)
+	}
+	if err != nil {
+		response := models.ErrorResponse(
+			"NOT_FOUND",
+			"File not found",
+			key,
+		)
+		return c.Status(fiber.StatusNotFound).JSON(response)
+	}
+
+	return c.Download(path, filepath.Base(path))
+}
@@ -33,10 +33,25 @@ func (h *HealthHandler) Home(c *fiber.Ctx) error {
 		"service": "yt-dlp API",
 		"version": "1.0.0",
 		"endpoints": fiber.Map{
-			"GET /api/v1/dl":      "Extract download URLs",
-			"GET /api/v1/info":    "Get video metadata",
-			"GET /api/v1/formats": "List available formats",
-			"GET /health":         "Health check",
+			"GET /api/v1/dl":                 "Extract download URLs",
+			"GET /api/v1/info":               "Get video metadata",
+			"GET /api/v1/formats":            "List available formats",
+			"GET /api/v1/playlist":           "List playlist or channel entries",
+			"GET /api/v1/subtitles":          "List subtitle and caption languages",
+			"GET /api/v1/subtitles/download": "Download a subtitle track",
+			"GET /api/v1/transcript":         "Get a timed plain-text transcript",
+			"GET /api/v1/merge":              "Download, merge and upload (blocking)",
+			"POST /api/v1/jobs":              "Queue a merge/upload job",
+			"GET /api/v1/jobs/:id":           "Get merge job status",
+			"DELETE /api/v1/jobs/:id":        "Cancel a merge job",
+			"GET /api/v1/merge/progress/:id": "Stream merge job progress (SSE)",
+			"POST /api/v1/batches":           "Download a playlist to storage",
+			"GET /api/v1/batches/:id":        "Get playlist batch status",
+			"DELETE /api/v1/batches/:id":     "Cancel a playlist batch",
+			"GET /api/v1/ws":                 "WebSocket control channel for merge jobs",
+			"GET /api/v1/files/:key/link":    "Mint a fresh download link for a stored file",
+			"GET /files/*":                   "Download a locally stored file (signed link)",
+			"GET /health":                    "Health check",
 		},
 	}
 
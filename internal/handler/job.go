@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/pavelc4/ytdpl-api-go/internal/models"
+	"github.com/pavelc4/ytdpl-api-go/internal/services"
+)
+
+type JobHandler struct {
+	jobManager *services.JobManager
+}
+
+func NewJobHandler(jobManager *services.JobManager) *JobHandler {
+	return &JobHandler{
+		jobManager: jobManager,
+	}
+}
+
+func (h *JobHandler) Create(c *fiber.Ctx) error {
+	var req models.MergeRequest
+	if err := c.BodyParser(&req); err != nil {
+		response := models.ErrorResponse(
+			"INVALID_INPUT",
+			"Invalid request body",
+			err.Error(),
+		)
+		return c.Status(fiber.StatusBadRequest).JSON(response)
+	}
+
+	if !isValidURL(req.URL) {
+		response := models.ErrorResponse(
+			"INVALID_INPUT",
+			"Invalid URL format",
+			"Please provide a valid video URL",
+		)
+		return c.Status(fiber.StatusBadRequest).JSON(response)
+	}
+
+	job, err := h.jobManager.Submit(req)
+	if err != nil {
+		return submitErrorResponse(c, err)
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(jobResponse(job))
+}
+
+func (h *JobHandler) Get(c *fiber.Ctx) error {
+	job, err := h.jobManager.Get(c.Params("id"))
+	if err != nil {
+		return jobErrorResponse(c, err)
+	}
+
+	return c.JSON(jobResponse(job))
+}
+
+func (h *JobHandler) Cancel(c *fiber.Ctx) error {
+	job, err := h.jobManager.Cancel(c.Params("id"), c.Query("cancel_token"))
+	if err != nil {
+		return jobErrorResponse(c, err)
+	}
+
+	return c.JSON(jobResponse(job))
+}
+
+// Progress streams a job's status and progress as Server-Sent Events until
+// the job finishes or the client goes away.
+func (h *JobHandler) Progress(c *fiber.Ctx) error {
+	events, unsubscribe, err := h.jobManager.Subscribe(c.Params("id"))
+	if err != nil {
+		return jobErrorResponse(c, err)
+	}
+
+	c.Set("Content-Type", "text/event-stream")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("Connection", "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer unsubscribe()
+
+		heartbeat := time.NewTicker(15 * time.Second)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case event, ok := <-events:
+				if !ok {
+					return
+				}
+				name, payload := "status", interface{}(event.Job)
+				if event.Progress != nil {
+					name, payload = "progress", event.Progress
+				}
+				data, err := json.Marshal(payload)
+				if err != nil {
+					return
+				}
+				fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, data)
+			case <-heartbeat.C:
+				fmt.Fprint(w, ": ping\n\n")
+			}
+
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+	})
+
+	return nil
+}
+
+func jobResponse(job *models.Job) models.Response {
+	response := models.SuccessResponse(job)
+	response.Meta = &models.Meta{
+		Timestamp: time.Now().Unix(),
+		Version:   "1.0",
+	}
+	return response
+}
+
+// submitError maps an error from submitting a job or batch to the HTTP
+// status and error reported for it, over HTTP and the WebSocket alike.
+func submitError(err error) (int, *models.ErrorInfo) {
+	switch {
+	case errors.Is(err, services.ErrInvalidRequest):
+		return fiber.StatusBadRequest, &models.ErrorInfo{
+			Code:    "INVALID_INPUT",
+			Message: "Invalid merge request",
+			Details: err.Error(),
+		}
+	case errors.Is(err, services.ErrStorageUnavailable):
+		return fiber.StatusServiceUnavailable, &models.ErrorInfo{
+			Code:    "SERVICE_UNAVAILABLE",
+			Message: "Storage not configured",
+			Details: "Storage backend is missing or misconfigured",
+		}
+	case errors.Is(err, services.ErrPoolFull):
+		return fiber.StatusServiceUnavailable, &models.ErrorInfo{
+			Code:    "QUEUE_FULL",
+			Message: "Too many queued jobs",
+			Details: "Please try again later",
+		}
+	default:
+		return fiber.StatusInternalServerError, &models.ErrorInfo{
+			Code:    "INTERNAL_ERROR",
+			Message: "Failed to queue job",
+			Details: err.Error(),
+		}
+	}
+}
+
+// jobError maps an error from looking up, canceling or subscribing to a job
+// to the HTTP status and error reported for it, over HTTP and the WebSocket
+// alike.
+func jobError(err error) (int, *models.ErrorInfo) {
+	switch {
+	case errors.Is(err, services.ErrJobNotFound):
+		return fiber.StatusNotFound, &models.ErrorInfo{
+			Code:    "NOT_FOUND",
+			Message: "Job not found",
+			Details: err.Error(),
+		}
+	case errors.Is(err, services.ErrJobFinished):
+		return fiber.StatusConflict, &models.ErrorInfo{
+			Code:    "CONFLICT",
+			Message: "Job already finished",
+			Details: err.Error(),
+		}
+	case errors.Is(err, services.ErrCancelToken):
+		return fiber.StatusForbidden, &models.ErrorInfo{
+			Code:    "FORBIDDEN",
+			Message: "Invalid cancel token",
+			Details: err.Error(),
+		}
+	default:
+		return fiber.StatusInternalServerError, &models.ErrorInfo{
+			Code:    "INTERNAL_ERROR",
+			Message: "Failed to process job request",
+			Details: err.Error(),
+		}
+	}
+}
+
+func submitErrorResponse(c *fiber.Ctx, err error) error {
+	status, info := submitError(err)
+	return c.Status(status).JSON(models.ErrorResponse(info.Code, info.Message, info.Details))
+}
+
+func jobErrorResponse(c *fiber.Ctx, err error) error {
+	status, info := jobError(err)
+	return c.Status(status).JSON(models.ErrorResponse(info.Code, info.Message, info.Details))
+}
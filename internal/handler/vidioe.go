@@ -2,34 +2,38 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"os"
-	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/google/uuid"
-	"github.com/patrickmn/go-cache"
 	"github.com/pavelc4/ytdpl-api-go/internal/models"
 	"github.com/pavelc4/ytdpl-api-go/internal/services"
 )
 
+const (
+	defaultPlaylistLimit = 50
+	maxPlaylistLimit     = 200
+	// maxMinValidity caps min_validity; direct URLs rarely live much longer,
+	// so larger values would force an extraction on every request.
+	maxMinValidity = 6 * time.Hour
+)
+
 type VideoHandler struct {
 	ytdlpService *services.YTDLPService
-	r2Service    *services.R2Service
-	cache        *cache.Cache
+	jobManager   *services.JobManager
 }
 
-func NewVideoHandler(ytdlpService *services.YTDLPService, r2Service *services.R2Service) *VideoHandler {
+func NewVideoHandler(ytdlpService *services.YTDLPService, jobManager *services.JobManager) *VideoHandler {
 	return &VideoHandler{
 		ytdlpService: ytdlpService,
-		r2Service:    r2Service,
-		cache:        cache.New(1*time.Hour, 2*time.Hour),
+		jobManager:   jobManager,
 	}
 }
 
 func isValidURL(url string) bool {
-	return len(url) > 0 && (url[:4] == "http" || url[:3] == "www")
+	return strings.HasPrefix(url, "http") || strings.HasPrefix(url, "www")
 }
 
 func (h *VideoHandler) GetDownloadURLs(c *fiber.Ctx) error {
@@ -43,7 +47,17 @@ func (h *VideoHandler) GetDownloadURLs(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(response)
 	}
 
-	data, err := h.ytdlpService.GetDownloadURLs(c.Context(), url)
+	minValidity := time.Duration(c.QueryInt("min_validity", 0)) * time.Second
+	if minValidity < 0 || minValidity > maxMinValidity {
+		response := models.ErrorResponse(
+			"INVALID_INPUT",
+			"Invalid min_validity",
+			fmt.Sprintf("min_validity must be between 0 and %d seconds", int(maxMinValidity.Seconds())),
+		)
+		return c.Status(fiber.StatusBadRequest).JSON(response)
+	}
+
+	data, err := h.ytdlpService.GetDownloadURLs(c.Context(), url, minValidity)
 	if err != nil {
 		response := models.ErrorResponse(
 			"EXTRACTION_FAILED",
@@ -73,103 +87,222 @@ func (h *VideoHandler) MergeAndUpload(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(response)
 	}
 
-	if h.r2Service == nil {
+	req := models.MergeRequest{
+		URL:     url,
+		Quality: c.Query("quality", "best"),
+		Type:    c.Query("type", "video"),
+		Format:  c.Query("format"),
+
+		Conversion:     c.Query("conversion"),
+		AudioQuality:   c.Query("audio_quality"),
+		EmbedMetadata:  c.QueryBool("embed_metadata"),
+		EmbedThumbnail: c.QueryBool("embed_thumbnail"),
+		FormatID:       c.Query("format_id"),
+		Selector:       c.Query("selector"),
+		SubtitleMode:   c.Query("subtitle_mode"),
+		AutoSubtitles:  c.QueryBool("auto_subtitles"),
+		Start:          c.Query("start"),
+		End:            c.Query("end"),
+		Chapter:        c.Query("chapter"),
+		ForceKeyframes: c.QueryBool("force_keyframes"),
+		SplitChapters:  c.QueryBool("split_chapters"),
+	}
+	for _, lang := range strings.Split(c.Query("subtitles"), ",") {
+		if lang = strings.TrimSpace(lang); lang != "" {
+			req.Subtitles = append(req.Subtitles, lang)
+		}
+	}
+
+	tags := models.AudioTags{
+		Title:       c.Query("title"),
+		Artist:      c.Query("artist"),
+		Album:       c.Query("album"),
+		AlbumArtist: c.Query("album_artist"),
+		Genre:       c.Query("genre"),
+		Date:        c.Query("date"),
+		Track:       c.Query("track"),
+	}
+	if len(tags.Fields()) > 0 {
+		req.Tags = &tags
+	}
+
+	job, err := h.jobManager.Submit(req)
+	if err != nil {
+		return submitErrorResponse(c, err)
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 15*time.Minute)
+	defer cancel()
+
+	jobID, cancelToken := job.ID, job.CancelToken
+	job, err = h.jobManager.Wait(ctx, jobID)
+	if err != nil {
+		h.jobManager.Cancel(jobID, cancelToken)
 		response := models.ErrorResponse(
-			"SERVICE_UNAVAILABLE",
-			"R2 Service not configured",
-			"R2 credentials are missing or invalid",
+			"DOWNLOAD_FAILED",
+			"Failed to download video and Merge ",
+			err.Error(),
 		)
-		return c.Status(fiber.StatusServiceUnavailable).JSON(response)
+		return c.Status(fiber.StatusInternalServerError).JSON(response)
 	}
 
-	quality := c.Query("quality", "best")
-	formatType := c.Query("type", "video")
-	containerFormat := c.Query("format", "mp4")
+	if job.Status != models.JobDone {
+		status := fiber.StatusInternalServerError
+		if job.Error != nil && job.Error.Code == "INVALID_INPUT" {
+			status = fiber.StatusBadRequest
+		}
+		response := models.Response{Error: job.Error}
+		return c.Status(status).JSON(response)
+	}
+
+	data := map[string]any{
+		"url":      job.Result.URL,
+		"key":      job.Result.Key,
+		"filename": job.Result.Filename,
+		"status":   "success",
+		"message":  "Video uploaded successfully",
+	}
+	if len(job.Result.Subtitles) > 0 {
+		data["subtitles"] = job.Result.Subtitles
+	}
+	if len(job.Result.Chapters) > 0 {
+		data["chapters"] = job.Result.Chapters
+	}
+	response := models.SuccessResponse(data)
 
-	cacheKey := fmt.Sprintf("upload_%s_%s_%s_%s", url, quality, formatType, containerFormat)
-	if cached, found := h.cache.Get(cacheKey); found {
-		return c.JSON(cached)
+	response.Meta = &models.Meta{
+		Timestamp: time.Now().Unix(),
+		Version:   "1.0",
 	}
 
-	tmpDir := filepath.Join(os.TempDir(), "ytdpl")
-	if err := os.MkdirAll(tmpDir, 0755); err != nil {
+	return c.JSON(response)
+}
+
+func (h *VideoHandler) GetVideoInfo(c *fiber.Ctx) error {
+	url := c.Query("url")
+	if !isValidURL(url) {
+		response := models.ErrorResponse(
+			"INVALID_INPUT",
+			"Invalid URL format",
+			"Please provide a valid video URL",
+		)
+		return c.Status(fiber.StatusBadRequest).JSON(response)
+	}
+
+	fields, err := services.ParseInfoFields(c.Query("fields"))
+	if err != nil {
+		response := models.ErrorResponse(
+			"INVALID_INPUT",
+			"Invalid fields",
+			err.Error(),
+		)
+		return c.Status(fiber.StatusBadRequest).JSON(response)
+	}
+
+	info, err := h.ytdlpService.GetVideoInfo(c.Context(), url)
+	if err != nil {
+		response := models.ErrorResponse(
+			"EXTRACTION_FAILED",
+			"Failed to extract video info",
+			err.Error(),
+		)
+		return c.Status(fiber.StatusInternalServerError).JSON(response)
+	}
+
+	data, err := services.SelectInfoFields(info, fields)
+	if err != nil {
 		response := models.ErrorResponse(
 			"INTERNAL_ERROR",
-			"Failed to create temporary directory",
+			"Failed to build video info",
 			err.Error(),
 		)
 		return c.Status(fiber.StatusInternalServerError).JSON(response)
 	}
 
-	ext := containerFormat
-	if formatType == "audio" {
-		ext = "mp3"
+	response := models.SuccessResponse(data)
+	response.Meta = &models.Meta{
+		Timestamp: time.Now().Unix(),
+		Version:   "1.0",
 	}
-	fileName := fmt.Sprintf("%d.%s", time.Now().UnixNano(), ext)
-	tempPath := filepath.Join(tmpDir, fileName)
 
-	defer os.Remove(tempPath)
+	return c.JSON(response)
+}
 
-	ctx, cancel := context.WithTimeout(c.Context(), 15*time.Minute)
-	defer cancel()
+func (h *VideoHandler) GetFormats(c *fiber.Ctx) error {
+	url := c.Query("url")
+	if !isValidURL(url) {
+		response := models.ErrorResponse(
+			"INVALID_INPUT",
+			"Invalid URL format",
+			"Please provide a valid video URL",
+		)
+		return c.Status(fiber.StatusBadRequest).JSON(response)
+	}
 
-	if err := h.ytdlpService.DownloadToFile(ctx, url, tempPath, quality, formatType, containerFormat); err != nil {
+	filter := services.FormatFilter{
+		Kind:      c.Query("kind"),
+		MaxHeight: c.QueryInt("max_height", 0),
+		VCodec:    c.Query("vcodec"),
+		Ext:       c.Query("ext"),
+		Sort:      c.Query("sort"),
+	}
+	if err := filter.Validate(); err != nil {
 		response := models.ErrorResponse(
-			"DOWNLOAD_FAILED",
-			"Failed to download video and Merge ",
+			"INVALID_INPUT",
+			"Invalid format filter",
 			err.Error(),
 		)
-		return c.Status(fiber.StatusInternalServerError).JSON(response)
+		return c.Status(fiber.StatusBadRequest).JSON(response)
 	}
 
-	folder := "vidioe"
-	if formatType == "audio" {
-		folder = "audio"
-	}
-	objectkey := fmt.Sprintf("%s/%s.%s", folder, uuid.New().String(), ext)
-	publicURL, err := h.r2Service.UploadFile(ctx, tempPath, objectkey)
+	data, err := h.ytdlpService.GetFormats(c.Context(), url, filter)
 	if err != nil {
 		response := models.ErrorResponse(
-			"UPLOAD_FAILED",
-			"Failed to upload video to storage R2 ",
+			"EXTRACTION_FAILED",
+			"Failed to extract formats",
 			err.Error(),
 		)
 		return c.Status(fiber.StatusInternalServerError).JSON(response)
 	}
 
-	response := models.SuccessResponse(map[string]string{
-		"url":      publicURL,
-		"filename": fileName,
-		"status":   "success",
-		"message":  "Video uploaded successfully",
-	})
-
+	response := models.SuccessResponse(data)
 	response.Meta = &models.Meta{
 		Timestamp: time.Now().Unix(),
 		Version:   "1.0",
 	}
 
-	h.cache.Set(cacheKey, response, cache.DefaultExpiration)
-
 	return c.JSON(response)
 }
 
-func (h *VideoHandler) GetVideoInfo(c *fiber.Ctx) error {
+// GetPlaylist lists the entries of a playlist, channel tab or mix, limit at
+// a time from offset.
+func (h *VideoHandler) GetPlaylist(c *fiber.Ctx) error {
 	url := c.Query("url")
 	if !isValidURL(url) {
 		response := models.ErrorResponse(
 			"INVALID_INPUT",
 			"Invalid URL format",
-			"Please provide a valid video URL",
+			"Please provide a valid playlist URL",
 		)
 		return c.Status(fiber.StatusBadRequest).JSON(response)
 	}
 
-	data, err := h.ytdlpService.GetVideoInfo(c.Context(), url)
+	offset := c.QueryInt("offset", 0)
+	limit := c.QueryInt("limit", defaultPlaylistLimit)
+	if offset < 0 || limit < 1 || limit > maxPlaylistLimit {
+		response := models.ErrorResponse(
+			"INVALID_INPUT",
+			"Invalid pagination",
+			fmt.Sprintf("offset must not be negative and limit must be between 1 and %d", maxPlaylistLimit),
+		)
+		return c.Status(fiber.StatusBadRequest).JSON(response)
+	}
+
+	data, err := h.ytdlpService.GetPlaylist(c.Context(), url, offset, limit)
 	if err != nil {
 		response := models.ErrorResponse(
 			"EXTRACTION_FAILED",
-			"Failed to extract video info",
+			"Failed to list playlist",
 			err.Error(),
 		)
 		return c.Status(fiber.StatusInternalServerError).JSON(response)
@@ -184,7 +317,7 @@ func (h *VideoHandler) GetVideoInfo(c *fiber.Ctx) error {
 	return c.JSON(response)
 }
 
-func (h *VideoHandler) GetFormats(c *fiber.Ctx) error {
+func (h *VideoHandler) GetSubtitles(c *fiber.Ctx) error {
 	url := c.Query("url")
 	if !isValidURL(url) {
 		response := models.ErrorResponse(
@@ -195,11 +328,11 @@ func (h *VideoHandler) GetFormats(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(response)
 	}
 
-	data, err := h.ytdlpService.GetFormats(c.Context(), url)
+	data, err := h.ytdlpService.GetSubtitles(c.Context(), url)
 	if err != nil {
 		response := models.ErrorResponse(
 			"EXTRACTION_FAILED",
-			"Failed to extract formats",
+			"Failed to extract subtitles",
 			err.Error(),
 		)
 		return c.Status(fiber.StatusInternalServerError).JSON(response)
@@ -213,3 +346,98 @@ func (h *VideoHandler) GetFormats(c *fiber.Ctx) error {
 
 	return c.JSON(response)
 }
+
+// DownloadSubtitle returns a single subtitle track as a file rather than
+// wrapped in the JSON envelope.
+func (h *VideoHandler) DownloadSubtitle(c *fiber.Ctx) error {
+	url := c.Query("url")
+	if !isValidURL(url) {
+		response := models.ErrorResponse(
+			"INVALID_INPUT",
+			"Invalid URL format",
+			"Please provide a valid video URL",
+		)
+		return c.Status(fiber.StatusBadRequest).JSON(response)
+	}
+
+	lang := c.Query("lang")
+	if lang == "" {
+		response := models.ErrorResponse(
+			"INVALID_INPUT",
+			"Missing subtitle language",
+			"Please provide a lang parameter, see /api/v1/subtitles",
+		)
+		return c.Status(fiber.StatusBadRequest).JSON(response)
+	}
+
+	format := c.Query("format", "vtt")
+	track, filename, err := h.ytdlpService.DownloadSubtitle(c.Context(), url, lang, format, c.QueryBool("auto"))
+	if err != nil {
+		return subtitleErrorResponse(c, err, "Failed to download subtitles")
+	}
+
+	c.Attachment(filename)
+	c.Set(fiber.HeaderContentType, services.SubtitleContentType(format))
+	return c.Send(track)
+}
+
+func (h *VideoHandler) GetTranscript(c *fiber.Ctx) error {
+	url := c.Query("url")
+	if !isValidURL(url) {
+		response := models.ErrorResponse(
+			"INVALID_INPUT",
+			"Invalid URL format",
+			"Please provide a valid video URL",
+		)
+		return c.Status(fiber.StatusBadRequest).JSON(response)
+	}
+
+	lang := c.Query("lang")
+	if lang == "" {
+		response := models.ErrorResponse(
+			"INVALID_INPUT",
+			"Missing subtitle language",
+			"Please provide a lang parameter, see /api/v1/subtitles",
+		)
+		return c.Status(fiber.StatusBadRequest).JSON(response)
+	}
+
+	data, err := h.ytdlpService.GetTranscript(c.Context(), url, lang, c.QueryBool("auto"), c.QueryBool("chapters"))
+	if err != nil {
+		return subtitleErrorResponse(c, err, "Failed to extract transcript")
+	}
+
+	response := models.SuccessResponse(data)
+	response.Meta = &models.Meta{
+		Timestamp: time.Now().Unix(),
+		Version:   "1.0",
+	}
+
+	return c.JSON(response)
+}
+
+func subtitleErrorResponse(c *fiber.Ctx, err error, message string) error {
+	switch {
+	case errors.Is(err, services.ErrInvalidRequest):
+		response := models.ErrorResponse(
+			"INVALID_INPUT",
+			"Invalid subtitle request",
+			err.Error(),
+		)
+		return c.Status(fiber.StatusBadRequest).JSON(response)
+	case errors.Is(err, services.ErrSubtitleNotFound):
+		response := models.ErrorResponse(
+			"NOT_FOUND",
+			"Subtitle track not found",
+			err.Error(),
+		)
+		return c.Status(fiber.StatusNotFound).JSON(response)
+	default:
+		response := models.ErrorResponse(
+			"EXTRACTION_FAILED",
+			message,
+			err.Error(),
+		)
+		return c.Status(fiber.StatusInternalServerError).JSON(response)
+	}
+}
@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"log"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/websocket/v2"
+	"github.com/pavelc4/ytdpl-api-go/internal/models"
+)
+
+// RateLimit reports whether the client with the given IP may make another
+// request in the current window.
+type RateLimit func(ip string) bool
+
+// wsReadLimit caps the size of client frames; a submit with every option
+// set fits easily.
+const wsReadLimit = 16 * 1024
+
+// wsMessage is the envelope for every frame in both directions.
+type wsMessage struct {
+	Type      string `json:"type"`
+	RequestID string `json:"request_id,omitempty"`
+	JobID     string `json:"job_id,omitempty"`
+	// CancelToken is only needed to cancel jobs this connection did not
+	// submit.
+	CancelToken string               `json:"cancel_token,omitempty"`
+	Request     *models.MergeRequest `json:"request,omitempty"`
+	Job         *models.Job          `json:"job,omitempty"`
+	Progress    *models.Progress     `json:"progress,omitempty"`
+	Error       *models.ErrorInfo    `json:"error,omitempty"`
+}
+
+// wsSession serializes writes to one connection and tracks its job
+// subscriptions.
+type wsSession struct {
+	conn *websocket.Conn
+
+	writeMu sync.Mutex
+	closed  bool
+
+	mu            sync.Mutex
+	subscriptions map[string]func()
+	// cancelTokens holds the cancel token of each job submitted over this
+	// connection.
+	cancelTokens map[string]string
+}
+
+func (s *wsSession) send(msg wsMessage) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	if s.closed {
+		return websocket.ErrCloseSent
+	}
+	return s.conn.WriteJSON(msg)
+}
+
+func (s *wsSession) sendError(requestID, jobID, code, message, details string) {
+	s.send(wsMessage{
+		Type:      "error",
+		RequestID: requestID,
+		JobID:     jobID,
+		Error: &models.ErrorInfo{
+			Code:    code,
+			Message: message,
+			Details: details,
+		},
+	})
+}
+
+// WebSocketUpgrade rejects non-WebSocket requests and remembers the client IP
+// for the rate limits applied to individual frames.
+func (h *JobHandler) WebSocketUpgrade(c *fiber.Ctx) error {
+	if !websocket.IsWebSocketUpgrade(c) {
+		return fiber.ErrUpgradeRequired
+	}
+	c.Locals("ip", c.IP())
+	return c.Next()
+}
+
+// WebSocket serves the merge job control channel. Every frame counts against
+// requestLimit and every submit additionally against mergeLimit, mirroring
+// the limits applied to the equivalent HTTP endpoints.
+//
+// Client frames:
+//
+//	{"type": "submit", "request_id": "...", "request": {"url": "...", ...}}
+//	{"type": "subscribe", "job_id": "..."}
+//	{"type": "cancel", "job_id": "...", "cancel_token": "..."}
+//
+// cancel_token may be omitted for jobs submitted over the same connection.
+// Server frames are "job" (reply to submit), "status", "progress" and
+// "error". Submitted jobs are subscribed to automatically.
+func (h *JobHandler) WebSocket(requestLimit, mergeLimit RateLimit) fiber.Handler {
+	return websocket.New(func(conn *websocket.Conn) {
+		ip, _ := conn.Locals("ip").(string)
+		session := &wsSession{
+			conn:          conn,
+			subscriptions: make(map[string]func()),
+			cancelTokens:  make(map[string]string),
+		}
+		defer session.closeSubscriptions()
+
+		conn.SetReadLimit(wsReadLimit)
+		for {
+			var msg wsMessage
+			if err := conn.ReadJSON(&msg); err != nil {
+				if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+					log.Printf("WebSocket read failed: %v", err)
+				}
+				return
+			}
+
+			if !requestLimit(ip) {
+				session.sendError(msg.RequestID, msg.JobID, "RATE_LIMITED", "Too many requests, please try again later.", "")
+				continue
+			}
+
+			switch msg.Type {
+			case "submit":
+				if !mergeLimit(ip) {
+					session.sendError(msg.RequestID, "", "RATE_LIMITED", "Upload limit reached, please try again later.", "")
+					continue
+				}
+				h.wsSubmit(session, msg)
+			case "subscribe":
+				h.wsSubscribe(session, msg.RequestID, msg.JobID)
+			case "cancel":
+				token := msg.CancelToken
+				if token == "" {
+					session.mu.Lock()
+					token = session.cancelTokens[msg.JobID]
+					session.mu.Unlock()
+				}
+				job, err := h.jobManager.Cancel(msg.JobID, token)
+				if err != nil {
+					session.sendJobError(msg.RequestID, msg.JobID, err)
+					continue
+				}
+				session.send(wsMessage{Type: "status", RequestID: msg.RequestID, JobID: job.ID, Job: job})
+			default:
+				session.sendError(msg.RequestID, msg.JobID, "INVALID_INPUT", "Unknown message type", msg.Type)
+			}
+		}
+	})
+}
+
+func (h *JobHandler) wsSubmit(session *wsSession, msg wsMessage) {
+	if msg.Request == nil || !isValidURL(msg.Request.URL) {
+		session.sendError(msg.RequestID, "", "INVALID_INPUT", "Invalid URL format", "Please provide a valid video URL")
+		return
+	}
+
+	job, err := h.jobManager.Submit(*msg.Request)
+	if err != nil {
+		_, info := submitError(err)
+		session.sendError(msg.RequestID, "", info.Code, info.Message, info.Details)
+		return
+	}
+
+	session.mu.Lock()
+	session.cancelTokens[job.ID] = job.CancelToken
+	session.mu.Unlock()
+
+	session.send(wsMessage{Type: "job", RequestID: msg.RequestID, JobID: job.ID, Job: job})
+	h.wsSubscribe(session, msg.RequestID, job.ID)
+}
+
+func (h *JobHandler) wsSubscribe(session *wsSession, requestID, jobID string) {
+	session.mu.Lock()
+	_, subscribed := session.subscriptions[jobID]
+	session.mu.Unlock()
+	if subscribed {
+		return
+	}
+
+	events, unsubscribe, err := h.jobManager.Subscribe(jobID)
+	if err != nil {
+		session.sendJobError(requestID, jobID, err)
+		return
+	}
+
+	session.mu.Lock()
+	session.subscriptions[jobID] = unsubscribe
+	session.mu.Unlock()
+
+	go func() {
+		defer func() {
+			session.mu.Lock()
+			delete(session.subscriptions, jobID)
+			session.mu.Unlock()
+			unsubscribe()
+		}()
+
+		for event := range events {
+			msg := wsMessage{Type: "status", RequestID: requestID, JobID: jobID, Job: event.Job}
+			if event.Progress != nil {
+				msg = wsMessage{Type: "progress", RequestID: requestID, JobID: jobID, Progress: event.Progress}
+			}
+			if err := session.send(msg); err != nil {
+				return
+			}
+		}
+	}()
+}
+
+func (s *wsSession) sendJobError(requestID, jobID string, err error) {
+	_, info := jobError(err)
+	s.sendError(requestID, jobID, info.Code, info.Message, info.Details)
+}
+
+// closeSubscriptions stops all forwarding goroutines. The connection is
+// recycled once the handler returns, so no write may happen afterwards.
+func (s *wsSession) closeSubscriptions() {
+	s.writeMu.Lock()
+	s.closed = true
+	s.writeMu.Unlock()
+
+	s.mu.Lock()
+	unsubscribes := make([]func(), 0, len(s.subscriptions))
+	for _, unsubscribe := range s.subscriptions {
+		unsubscribes = append(unsubscribes, unsubscribe)
+	}
+	s.mu.Unlock()
+
+	for _, unsubscribe := range unsubscribes {
+		unsubscribe()
+	}
+}
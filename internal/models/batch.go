@@ -0,0 +1,49 @@
+package models
+
+// BatchRequest downloads the entries of a playlist with the options of a
+// merge request, whose URL is the playlist's.
+type BatchRequest struct {
+	MergeRequest
+	Offset int `json:"offset,omitempty"`
+	Limit  int `json:"limit,omitempty"`
+	// Concurrency caps how many entries of the batch are queued or running
+	// at once, so one playlist cannot take over the worker pool.
+	Concurrency int `json:"concurrency,omitempty"`
+	// Zip additionally bundles the downloaded files and the manifest into a
+	// single archive.
+	Zip bool `json:"zip,omitempty"`
+}
+
+type BatchEntry struct {
+	// Index is the entry's 1-based position in the playlist.
+	Index  int          `json:"index"`
+	ID     string       `json:"id"`
+	Title  string       `json:"title,omitempty"`
+	URL    string       `json:"url"`
+	Status JobStatus    `json:"status"`
+	JobID  string       `json:"job_id,omitempty"`
+	Result *MergeResult `json:"result,omitempty"`
+	Error  *ErrorInfo   `json:"error,omitempty"`
+}
+
+// Batch is a playlist download. It is done once every entry has finished,
+// whether or not it succeeded, and the manifest has been uploaded.
+type Batch struct {
+	ID            string       `json:"id"`
+	Status        JobStatus    `json:"status"`
+	Request       BatchRequest `json:"request"`
+	PlaylistID    string       `json:"playlist_id,omitempty"`
+	PlaylistTitle string       `json:"playlist_title,omitempty"`
+	Uploader      string       `json:"uploader,omitempty"`
+	// Prefix is the storage key prefix all of the batch's objects share.
+	Prefix      string       `json:"prefix,omitempty"`
+	Total       int          `json:"total"`
+	Succeeded   int          `json:"succeeded"`
+	Failed      int          `json:"failed"`
+	Entries     []BatchEntry `json:"entries"`
+	ManifestURL string       `json:"manifest_url,omitempty"`
+	ZipURL      string       `json:"zip_url,omitempty"`
+	Error       *ErrorInfo   `json:"error,omitempty"`
+	CreatedAt   int64        `json:"created_at"`
+	UpdatedAt   int64        `json:"updated_at"`
+}
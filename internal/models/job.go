@@ -0,0 +1,134 @@
+package models
+
+type JobStatus string
+
+const (
+	JobQueued    JobStatus = "queued"
+	JobRunning   JobStatus = "running"
+	JobUploading JobStatus = "uploading"
+	JobDone      JobStatus = "done"
+	JobFailed    JobStatus = "failed"
+	JobCanceled  JobStatus = "canceled"
+)
+
+// Finished reports whether the job has reached a terminal state.
+func (s JobStatus) Finished() bool {
+	return s == JobDone || s == JobFailed || s == JobCanceled
+}
+
+type MergeRequest struct {
+	URL     string `json:"url"`
+	Quality string `json:"quality,omitempty"`
+	Type    string `json:"type,omitempty"`
+	// Format is the container for video and the codec for audio (or
+	// "original" to keep the source stream).
+	Format string `json:"format,omitempty"`
+	// Conversion allows video streams the container does not accept:
+	// "remux" copies them anyway, "transcode" re-encodes them.
+	Conversion string `json:"conversion,omitempty"`
+	// AudioQuality is a VBR level (0-10) or bitrate such as 192K for lossy
+	// audio formats.
+	AudioQuality string `json:"audio_quality,omitempty"`
+	// EmbedMetadata writes the video's title, uploader, album and upload
+	// date as audio tags, and EmbedThumbnail its thumbnail as cover art.
+	// Tags overrides individual values and implies EmbedMetadata.
+	EmbedMetadata  bool       `json:"embed_metadata,omitempty"`
+	EmbedThumbnail bool       `json:"embed_thumbnail,omitempty"`
+	Tags           *AudioTags `json:"tags,omitempty"`
+	// FormatID (from /formats, e.g. "137+140") or Selector (a yt-dlp format
+	// selector expression) override Quality.
+	FormatID string `json:"format_id,omitempty"`
+	Selector string `json:"selector,omitempty"`
+	// Subtitles lists subtitle languages to embed in the video
+	// (SubtitleMode "embed", the default) or upload next to it as WebVTT
+	// ("sidecar"). AutoSubtitles lets automatic captions stand in for
+	// languages without subtitles.
+	Subtitles     []string `json:"subtitles,omitempty"`
+	SubtitleMode  string   `json:"subtitle_mode,omitempty"`
+	AutoSubtitles bool     `json:"auto_subtitles,omitempty"`
+	// Start and End (seconds or [hh:]mm:ss) or Chapter (a title or 1-based
+	// index) download only part of the video. ForceKeyframes re-encodes
+	// around the cuts so they are exact rather than at the nearest keyframe.
+	Start          string `json:"start,omitempty"`
+	End            string `json:"end,omitempty"`
+	Chapter        string `json:"chapter,omitempty"`
+	ForceKeyframes bool   `json:"force_keyframes,omitempty"`
+	// SplitChapters stores one object per chapter instead of one file.
+	SplitChapters bool `json:"split_chapters,omitempty"`
+}
+
+// AudioTags are caller-supplied tag values; empty fields keep what
+// yt-dlp wrote.
+type AudioTags struct {
+	Title       string `json:"title,omitempty"`
+	Artist      string `json:"artist,omitempty"`
+	Album       string `json:"album,omitempty"`
+	AlbumArtist string `json:"album_artist,omitempty"`
+	Genre       string `json:"genre,omitempty"`
+	Date        string `json:"date,omitempty"`
+	Track       string `json:"track,omitempty"`
+}
+
+type Tag struct {
+	Name  string
+	Value string
+}
+
+// Fields returns the set tags under their ffmpeg metadata names.
+func (t *AudioTags) Fields() []Tag {
+	all := []Tag{
+		{"title", t.Title},
+		{"artist", t.Artist},
+		{"album", t.Album},
+		{"album_artist", t.AlbumArtist},
+		{"genre", t.Genre},
+		{"date", t.Date},
+		{"track", t.Track},
+	}
+	var set []Tag
+	for _, tag := range all {
+		if tag.Value != "" {
+			set = append(set, tag)
+		}
+	}
+	return set
+}
+
+type MergeResult struct {
+	URL      string `json:"url"`
+	Key      string `json:"key,omitempty"`
+	Filename string `json:"filename"`
+	SHA256   string `json:"sha256,omitempty"`
+	// Deduplicated is set when an existing object was reused instead of
+	// downloading the video again.
+	Deduplicated bool `json:"deduplicated,omitempty"`
+	// Subtitles are the sidecar subtitle files uploaded with the video.
+	Subtitles []SubtitleFile `json:"subtitles,omitempty"`
+	// Chapters are the per-chapter objects of a split merge, which has no
+	// single URL.
+	Chapters []ChapterFile `json:"chapters,omitempty"`
+}
+
+type ChapterFile struct {
+	// Index is the chapter's 1-based position in the video.
+	Index int     `json:"index"`
+	Title string  `json:"title"`
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	URL   string  `json:"url"`
+	Key   string  `json:"key"`
+}
+
+type Job struct {
+	ID      string       `json:"id"`
+	Status  JobStatus    `json:"status"`
+	Request MergeRequest `json:"request"`
+	// CancelToken is handed only to the submission it belongs to and is
+	// required to cancel the job on its behalf.
+	CancelToken string       `json:"cancel_token,omitempty"`
+	Progress    *Progress    `json:"progress,omitempty"`
+	Result      *MergeResult `json:"result,omitempty"`
+	Error       *ErrorInfo   `json:"error,omitempty"`
+	CreatedAt   int64        `json:"created_at"`
+	UpdatedAt   int64        `json:"updated_at"`
+}
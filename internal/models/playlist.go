@@ -0,0 +1,43 @@
+package models
+
+// YTDLPPlaylistOutput is yt-dlp's --flat-playlist -J output for a playlist,
+// channel tab or mix.
+type YTDLPPlaylistOutput struct {
+	ID            string               `json:"id"`
+	Title         string               `json:"title"`
+	Uploader      string               `json:"uploader"`
+	Channel       string               `json:"channel"`
+	WebpageURL    string               `json:"webpage_url"`
+	PlaylistCount *int                 `json:"playlist_count"`
+	Entries       []YTDLPPlaylistEntry `json:"entries"`
+}
+
+type YTDLPPlaylistEntry struct {
+	ID         string      `json:"id"`
+	Title      string      `json:"title"`
+	Duration   float64     `json:"duration"`
+	Thumbnail  string      `json:"thumbnail"`
+	Thumbnails []Thumbnail `json:"thumbnails"`
+	URL        string      `json:"url"`
+	WebpageURL string      `json:"webpage_url"`
+}
+
+type PlaylistEntry struct {
+	ID        string `json:"id"`
+	Title     string `json:"title"`
+	Duration  int    `json:"duration,omitempty"`
+	Thumbnail string `json:"thumbnail,omitempty"`
+	URL       string `json:"url"`
+}
+
+type Playlist struct {
+	ID       string `json:"id"`
+	Title    string `json:"title"`
+	Uploader string `json:"uploader,omitempty"`
+	// EntryCount is the total number of entries, when the site reports
+	// one; mixes are endless and have none.
+	EntryCount *int            `json:"entry_count,omitempty"`
+	Offset     int             `json:"offset"`
+	Limit      int             `json:"limit"`
+	Entries    []PlaylistEntry `json:"entries"`
+}
@@ -0,0 +1,27 @@
+package models
+
+type ProgressPhase string
+
+const (
+	PhaseDownloadVideo ProgressPhase = "download_video"
+	PhaseDownloadAudio ProgressPhase = "download_audio"
+	PhaseMerge         ProgressPhase = "merge"
+	PhasePostprocess   ProgressPhase = "postprocess"
+	PhaseUpload        ProgressPhase = "upload"
+)
+
+type Progress struct {
+	Phase           ProgressPhase `json:"phase"`
+	Percent         float64       `json:"percent"`
+	DownloadedBytes int64         `json:"downloaded_bytes,omitempty"`
+	TotalBytes      int64         `json:"total_bytes,omitempty"`
+	Speed           float64       `json:"speed,omitempty"` // bytes per second
+	ETA             int           `json:"eta,omitempty"`   // seconds
+}
+
+// JobEvent is pushed to subscribers of a job. Exactly one of Job or
+// Progress is set.
+type JobEvent struct {
+	Job      *Job      `json:"job,omitempty"`
+	Progress *Progress `json:"progress,omitempty"`
+}
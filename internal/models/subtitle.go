@@ -0,0 +1,52 @@
+package models
+
+type SubtitleTrack struct {
+	Ext  string `json:"ext"`
+	URL  string `json:"url"`
+	Name string `json:"name"`
+}
+
+type SubtitleLanguage struct {
+	Lang      string   `json:"lang"`
+	Name      string   `json:"name,omitempty"`
+	Automatic bool     `json:"automatic"`
+	Formats   []string `json:"formats"`
+}
+
+type SubtitlesResponse struct {
+	VideoID           string             `json:"video_id"`
+	Subtitles         []SubtitleLanguage `json:"subtitles"`
+	AutomaticCaptions []SubtitleLanguage `json:"automatic_captions"`
+}
+
+// SubtitleFile is a subtitle track uploaded next to a merged video.
+type SubtitleFile struct {
+	Lang string `json:"lang"`
+	URL  string `json:"url"`
+	Key  string `json:"key"`
+}
+
+type TranscriptSegment struct {
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	Text  string  `json:"text"`
+}
+
+type TranscriptChapter struct {
+	Title    string              `json:"title"`
+	Start    float64             `json:"start"`
+	End      float64             `json:"end"`
+	Text     string              `json:"text"`
+	Segments []TranscriptSegment `json:"segments"`
+}
+
+type Transcript struct {
+	VideoID   string              `json:"video_id"`
+	Lang      string              `json:"lang"`
+	Automatic bool                `json:"automatic"`
+	Text      string              `json:"text"`
+	Segments  []TranscriptSegment `json:"segments"`
+	// Chapters groups Segments by chapter when requested and the video
+	// has chapters.
+	Chapters []TranscriptChapter `json:"chapters,omitempty"`
+}
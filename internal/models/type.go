@@ -1,7 +1,10 @@
 package models
 
 type YTDLPOutput struct {
-	ID          string        `json:"id"`
+	ID           string `json:"id"`
+	ExtractorKey string `json:"extractor_key"`
+	// Epoch is when yt-dlp extracted the video, as a Unix timestamp.
+	Epoch       int64         `json:"epoch"`
 	Title       string        `json:"title"`
 	Duration    float64       `json:"duration"`
 	Thumbnail   string        `json:"thumbnail"`
@@ -10,11 +13,58 @@ type YTDLPOutput struct {
 	ViewCount   int           `json:"view_count"`
 	UploadDate  string        `json:"upload_date"`
 	Formats     []VideoFormat `json:"formats"`
+
+	Channel      string      `json:"channel"`
+	ChannelID    string      `json:"channel_id"`
+	ChannelURL   string      `json:"channel_url"`
+	LikeCount    *int64      `json:"like_count"`
+	CommentCount *int64      `json:"comment_count"`
+	Tags         []string    `json:"tags"`
+	Categories   []string    `json:"categories"`
+	Chapters     []Chapter   `json:"chapters"`
+	AgeLimit     int         `json:"age_limit"`
+	Availability string      `json:"availability"`
+	LiveStatus   string      `json:"live_status"`
+	WebpageURL   string      `json:"webpage_url"`
+	Extractor    string      `json:"extractor"`
+	AspectRatio  float64     `json:"aspect_ratio"`
+	Thumbnails   []Thumbnail `json:"thumbnails"`
+
+	// Subtitles and AutomaticCaptions map language codes to the formats
+	// each track is offered in.
+	Subtitles         map[string][]SubtitleTrack `json:"subtitles"`
+	AutomaticCaptions map[string][]SubtitleTrack `json:"automatic_captions"`
+
+	// URL is set when the selected format is a single muxed stream;
+	// otherwise RequestedFormats holds one entry per merged stream.
+	URL              string            `json:"url"`
+	RequestedFormats []RequestedFormat `json:"requested_formats"`
+}
+
+type RequestedFormat struct {
+	FormatID string `json:"format_id"`
+	URL      string `json:"url"`
 }
 
 type VideoURL struct {
 	VideoURL string `json:"video_url"`
 	AudioURL string `json:"audio_url,omitempty"`
+	// ExpiresAt is the Unix time the URLs stop working, when the site signs
+	// one into them.
+	ExpiresAt int64 `json:"expires_at,omitempty"`
+}
+
+type Chapter struct {
+	StartTime float64 `json:"start_time"`
+	EndTime   float64 `json:"end_time"`
+	Title     string  `json:"title"`
+}
+
+type Thumbnail struct {
+	ID     string `json:"id,omitempty"`
+	URL    string `json:"url"`
+	Width  int    `json:"width,omitempty"`
+	Height int    `json:"height,omitempty"`
 }
 
 type VideoInfo struct {
@@ -26,17 +76,56 @@ type VideoInfo struct {
 	Uploader    string `json:"uploader"`
 	ViewCount   int    `json:"view_count"`
 	UploadDate  string `json:"upload_date,omitempty"`
+
+	Channel      string      `json:"channel,omitempty"`
+	ChannelID    string      `json:"channel_id,omitempty"`
+	ChannelURL   string      `json:"channel_url,omitempty"`
+	LikeCount    *int64      `json:"like_count,omitempty"`
+	CommentCount *int64      `json:"comment_count,omitempty"`
+	Tags         []string    `json:"tags,omitempty"`
+	Categories   []string    `json:"categories,omitempty"`
+	Chapters     []Chapter   `json:"chapters,omitempty"`
+	AgeLimit     int         `json:"age_limit"`
+	Availability string      `json:"availability,omitempty"`
+	LiveStatus   string      `json:"live_status,omitempty"`
+	WebpageURL   string      `json:"webpage_url,omitempty"`
+	Extractor    string      `json:"extractor,omitempty"`
+	AspectRatio  float64     `json:"aspect_ratio,omitempty"`
+	Thumbnails   []Thumbnail `json:"thumbnails,omitempty"`
+}
+
+// MinimalInfoFields are the VideoInfo fields returned for fields=minimal.
+var MinimalInfoFields = []string{
+	"id", "title", "duration", "thumbnail", "description",
+	"uploader", "view_count", "upload_date",
 }
 
 type VideoFormat struct {
-	FormatID   string  `json:"format_id"`
-	Ext        string  `json:"ext"`
-	Resolution string  `json:"resolution"`
-	Quality    string  `json:"quality"`
-	Filesize   int64   `json:"filesize"`
-	FPS        float64 `json:"fps,omitempty"`
-	VCodec     string  `json:"vcodec,omitempty"`
-	ACodec     string  `json:"acodec,omitempty"`
+	FormatID   string `json:"format_id"`
+	FormatNote string `json:"format_note,omitempty"`
+	// Kind is video, audio, muxed or other (e.g. storyboards); it is derived
+	// from the codecs rather than reported by yt-dlp.
+	Kind      string `json:"kind,omitempty"`
+	Ext       string `json:"ext"`
+	Container string `json:"container,omitempty"`
+	Protocol  string `json:"protocol,omitempty"`
+	// Quality is yt-dlp's relative ranking of the format; higher is better.
+	Quality        float64 `json:"quality"`
+	Resolution     string  `json:"resolution"`
+	Width          int     `json:"width,omitempty"`
+	Height         int     `json:"height,omitempty"`
+	FPS            float64 `json:"fps,omitempty"`
+	DynamicRange   string  `json:"dynamic_range,omitempty"`
+	VCodec         string  `json:"vcodec,omitempty"`
+	ACodec         string  `json:"acodec,omitempty"`
+	AudioChannels  int     `json:"audio_channels,omitempty"`
+	ASR            int     `json:"asr,omitempty"`
+	Language       string  `json:"language,omitempty"`
+	TBR            float64 `json:"tbr,omitempty"`
+	ABR            float64 `json:"abr,omitempty"`
+	VBR            float64 `json:"vbr,omitempty"`
+	Filesize       int64   `json:"filesize"`
+	FilesizeApprox int64   `json:"filesize_approx,omitempty"`
 }
 
 type FormatsResponse struct {
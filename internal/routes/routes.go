@@ -4,28 +4,30 @@ import (
 	"time"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/gofiber/fiber/v2/middleware/limiter"
+	"github.com/patrickmn/go-cache"
 	"github.com/pavelc4/ytdpl-api-go/config"
 	handlers "github.com/pavelc4/ytdpl-api-go/internal/handler"
 )
 
-func SetupRoutes(app *fiber.App, cfg *config.Config, videoHandler *handlers.VideoHandler, healthHandler *handlers.HealthHandler) {
-	app.Use(limiter.New(limiter.Config{
-		Max:        20,
-		Expiration: 1 * time.Minute,
-		KeyGenerator: func(c *fiber.Ctx) string {
-			return c.IP()
-		},
-		LimitReached: func(c *fiber.Ctx) error {
-			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
-				"success": false,
-				"error": fiber.Map{
-					"code":    fiber.StatusTooManyRequests,
-					"message": "Too many requests, please try again later.",
-				},
-			})
-		},
-	}))
+const (
+	requestLimit = 20
+	uploadLimit  = 5
+	limitWindow  = 1 * time.Minute
+)
+
+func SetupRoutes(app *fiber.App, cfg *config.Config, videoHandler *handlers.VideoHandler, jobHandler *handlers.JobHandler, batchHandler *handlers.BatchHandler, fileHandler *handlers.FileHandler, healthHandler *handlers.HealthHandler) {
+	// Registered ahead of the global limiter: media players issue many
+	// range requests and links are already protected by their signature.
+	if fileHandler.ServesFiles() {
+		app.Get("/files/*", fileHandler.Serve)
+	}
+
+	// HTTP requests and WebSocket frames of one IP draw from the same
+	// budgets.
+	requestCounter := newIPCounter(requestLimit)
+	uploadCounter := newIPCounter(uploadLimit)
+
+	app.Use(newLimiter(requestCounter, "Too many requests, please try again later."))
 
 	app.Get("/", healthHandler.Home)
 	app.Get("/health", healthHandler.Check)
@@ -35,21 +37,51 @@ func SetupRoutes(app *fiber.App, cfg *config.Config, videoHandler *handlers.Vide
 	api.Get("/dl", videoHandler.GetDownloadURLs)
 	api.Get("/info", videoHandler.GetVideoInfo)
 	api.Get("/formats", videoHandler.GetFormats)
+	api.Get("/playlist", videoHandler.GetPlaylist)
+	api.Get("/subtitles", videoHandler.GetSubtitles)
+	api.Get("/subtitles/download", videoHandler.DownloadSubtitle)
+	api.Get("/transcript", videoHandler.GetTranscript)
+
+	uploadLimiter := newLimiter(uploadCounter, "Upload limit reached, please try again later.")
+
+	api.Get("/merge", uploadLimiter, videoHandler.MergeAndUpload)
+	api.Get("/merge/progress/:id", jobHandler.Progress)
+
+	api.Post("/jobs", uploadLimiter, jobHandler.Create)
+	api.Get("/jobs/:id", jobHandler.Get)
+	api.Delete("/jobs/:id", jobHandler.Cancel)
+
+	api.Post("/batches", uploadLimiter, batchHandler.Create)
+	api.Get("/batches/:id", batchHandler.Get)
+	api.Delete("/batches/:id", batchHandler.Cancel)
+
+	api.Get("/files/+/link", fileHandler.Link)
+
+	api.Get("/ws", jobHandler.WebSocketUpgrade, jobHandler.WebSocket(requestCounter, uploadCounter))
+}
+
+func newLimiter(counter handlers.RateLimit, message string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if counter(c.IP()) {
+			return c.Next()
+		}
+		return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+			"success": false,
+			"error": fiber.Map{
+				"code":    fiber.StatusTooManyRequests,
+				"message": message,
+			},
+		})
+	}
+}
 
-	api.Get("/merge", limiter.New(limiter.Config{
-		Max:        5,
-		Expiration: 1 * time.Minute,
-		KeyGenerator: func(c *fiber.Ctx) string {
-			return c.IP()
-		},
-		LimitReached: func(c *fiber.Ctx) error {
-			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
-				"success": false,
-				"error": fiber.Map{
-					"code":    fiber.StatusTooManyRequests,
-					"message": "Upload limit reached, please try again later.",
-				},
-			})
-		},
-	}), videoHandler.MergeAndUpload)
+// newIPCounter applies a fixed-window limit of max calls per IP. It backs both
+// the HTTP limiters and the limits on WebSocket frames.
+func newIPCounter(max int) handlers.RateLimit {
+	counts := cache.New(limitWindow, 2*limitWindow)
+	return func(ip string) bool {
+		counts.Add(ip, 0, limitWindow)
+		n, err := counts.IncrementInt(ip, 1)
+		return err == nil && n <= max
+	}
 }
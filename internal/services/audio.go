@@ -0,0 +1,91 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/pavelc4/ytdpl-api-go/internal/models"
+)
+
+// audioOriginal keeps the source audio stream as it is, without re-encoding.
+const audioOriginal = "original"
+
+// audioFormats maps the format values accepted for type=audio to the
+// extension yt-dlp gives the extracted file. The extension of original
+// depends on the source codec and is only known afterwards.
+var audioFormats = map[string]string{
+	"mp3":         "mp3",
+	"m4a":         "m4a",
+	"aac":         "m4a",
+	"opus":        "opus",
+	"vorbis":      "ogg",
+	"flac":        "flac",
+	"wav":         "wav",
+	audioOriginal: "",
+}
+
+// losslessAudio formats have no quality setting.
+var losslessAudio = []string{"flac", "wav"}
+
+// audioQualityPattern accepts yt-dlp's VBR levels 0 (best) to 10 (worst) or
+// a bitrate such as 192K.
+var audioQualityPattern = regexp.MustCompile(`^(10|[0-9]|[0-9]{2,3}[kK])$`)
+
+func validateAudio(req models.MergeRequest) error {
+	if _, ok := audioFormats[req.Format]; !ok {
+		return fmt.Errorf("%w: unsupported audio format %q, use one of %s", ErrInvalidRequest, req.Format, strings.Join(audioFormatNames(), ", "))
+	}
+	if req.AudioQuality == "" {
+		return nil
+	}
+	if req.Format == audioOriginal || slices.Contains(losslessAudio, req.Format) {
+		return fmt.Errorf("%w: audio_quality cannot be used with format %s", ErrInvalidRequest, req.Format)
+	}
+	if !audioQualityPattern.MatchString(req.AudioQuality) {
+		return fmt.Errorf("%w: audio_quality must be 0-10 or a bitrate such as 192K", ErrInvalidRequest)
+	}
+	return nil
+}
+
+// audioArgs returns the yt-dlp options that extract the audio of req.
+func audioArgs(req models.MergeRequest) []string {
+	format := req.Format
+	if _, ok := audioFormats[format]; !ok {
+		// Jobs queued before audio formats were selectable.
+		format = "mp3"
+	}
+	if format == audioOriginal {
+		return []string{"--extract-audio", "--audio-format", "best"}
+	}
+
+	args := []string{"--extract-audio", "--audio-format", format}
+	if !slices.Contains(losslessAudio, format) {
+		quality := req.AudioQuality
+		if quality == "" {
+			quality = "0"
+		}
+		args = append(args, "--audio-quality", quality)
+	}
+	return args
+}
+
+// outputExt predicts the extension of the file a merge of req produces, or
+// returns "" when it cannot be known before downloading.
+func outputExt(req models.MergeRequest) string {
+	if req.Type == "audio" {
+		return audioFormats[req.Format]
+	}
+	return req.Format
+}
+
+func audioFormatNames() []string {
+	names := make([]string, 0, len(audioFormats))
+	for name := range audioFormats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
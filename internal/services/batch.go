@@ -0,0 +1,635 @@
+package services
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pavelc4/ytdpl-api-go/internal/models"
+)
+
+const (
+	batchPrefix             = "playlists/"
+	defaultBatchLimit       = 50
+	maxBatchLimit           = 500
+	defaultBatchConcurrency = 2
+	maxBatchConcurrency     = 5
+	// batchRetryDelay is how long an entry waits before trying again when
+	// the merge queue is full.
+	batchRetryDelay = 5 * time.Second
+)
+
+var (
+	ErrBatchNotFound = errors.New("batch not found")
+	ErrBatchFinished = errors.New("batch already finished")
+)
+
+var unsafeKeyChars = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+type batchEntry struct {
+	batch  models.Batch
+	cancel context.CancelFunc
+}
+
+// BatchManager downloads playlists by submitting one merge job per entry to
+// the JobManager, at most Concurrency of them at a time per playlist. Entry
+// jobs deduplicate like any other, and their output is then copied under a
+// prefix of the batch's own, next to a JSON manifest and, optionally, a ZIP
+// archive. Batches are kept in memory only; the entry jobs themselves are
+// persisted like any other job.
+type BatchManager struct {
+	ytdlpService *YTDLPService
+	jobManager   *JobManager
+	storage      Storage
+	tmpDir       string
+
+	mu      sync.Mutex
+	batches map[string]*batchEntry
+}
+
+func NewBatchManager(ytdlpService *YTDLPService, jobManager *JobManager, storage Storage) *BatchManager {
+	return &BatchManager{
+		ytdlpService: ytdlpService,
+		jobManager:   jobManager,
+		storage:      storage,
+		tmpDir:       filepath.Join(os.TempDir(), "ytdpl"),
+		batches:      make(map[string]*batchEntry),
+	}
+}
+
+// Submit validates req, fills in defaults and starts the batch. The playlist
+// is enumerated in the background.
+func (m *BatchManager) Submit(req models.BatchRequest) (*models.Batch, error) {
+	if m.storage == nil {
+		return nil, ErrStorageUnavailable
+	}
+
+	req.MergeRequest = withMergeDefaults(req.MergeRequest)
+	if err := validateMergeRequest(req.MergeRequest); err != nil {
+		return nil, err
+	}
+
+	if req.Limit == 0 {
+		req.Limit = defaultBatchLimit
+	}
+	if req.Concurrency == 0 {
+		req.Concurrency = defaultBatchConcurrency
+	}
+	if req.Offset < 0 {
+		return nil, fmt.Errorf("%w: offset must not be negative", ErrInvalidRequest)
+	}
+	if req.Limit < 1 || req.Limit > maxBatchLimit {
+		return nil, fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidRequest, maxBatchLimit)
+	}
+	if req.Concurrency < 1 || req.Concurrency > maxBatchConcurrency {
+		return nil, fmt.Errorf("%w: concurrency must be between 1 and %d", ErrInvalidRequest, maxBatchConcurrency)
+	}
+
+	now := time.Now().Unix()
+	ctx, cancel := context.WithCancel(context.Background())
+	entry := &batchEntry{
+		batch: models.Batch{
+			ID:        uuid.New().String(),
+			Status:    models.JobQueued,
+			Request:   req,
+			Entries:   []models.BatchEntry{},
+			CreatedAt: now,
+			UpdatedAt: now,
+		},
+		cancel: cancel,
+	}
+
+	m.mu.Lock()
+	m.batches[entry.batch.ID] = entry
+	batch := entry.snapshot()
+	m.mu.Unlock()
+
+	go m.run(ctx, entry)
+
+	return batch, nil
+}
+
+func (m *BatchManager) Get(id string) (*models.Batch, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.batches[id]
+	if !ok {
+		return nil, ErrBatchNotFound
+	}
+	return entry.snapshot(), nil
+}
+
+// Cancel stops a batch: entries that have not started are skipped and the
+// jobs of running ones are canceled. No manifest is written.
+func (m *BatchManager) Cancel(id string) (*models.Batch, error) {
+	m.mu.Lock()
+	entry, ok := m.batches[id]
+	if !ok {
+		m.mu.Unlock()
+		return nil, ErrBatchNotFound
+	}
+	if entry.batch.Status.Finished() {
+		m.mu.Unlock()
+		return nil, ErrBatchFinished
+	}
+	m.finish(entry, models.JobCanceled, nil)
+	batch := entry.snapshot()
+	m.mu.Unlock()
+
+	entry.cancel()
+	return batch, nil
+}
+
+func (m *BatchManager) run(ctx context.Context, entry *batchEntry) {
+	defer entry.cancel()
+
+	req := entry.batch.Request
+	playlist, err := m.ytdlpService.GetPlaylist(ctx, req.URL, req.Offset, req.Limit)
+	if err != nil {
+		m.fail(entry, &models.ErrorInfo{
+			Code:    "EXTRACTION_FAILED",
+			Message: "Failed to list playlist entries",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	playlistID := unsafeKeyChars.ReplaceAllString(playlist.ID, "_")
+	if playlistID == "" {
+		playlistID = "playlist"
+	}
+
+	m.mu.Lock()
+	if entry.batch.Status.Finished() {
+		m.mu.Unlock()
+		return
+	}
+	b := &entry.batch
+	b.Status = models.JobRunning
+	b.PlaylistID = playlist.ID
+	b.PlaylistTitle = playlist.Title
+	b.Uploader = playlist.Uploader
+	b.Prefix = fmt.Sprintf("%s%s/%s/", batchPrefix, playlistID, b.ID)
+	for i, e := range playlist.Entries {
+		b.Entries = append(b.Entries, models.BatchEntry{
+			Index:  req.Offset + i + 1,
+			ID:     e.ID,
+			Title:  e.Title,
+			URL:    e.URL,
+			Status: models.JobQueued,
+		})
+	}
+	b.Total = len(b.Entries)
+	b.UpdatedAt = time.Now().Unix()
+	m.mu.Unlock()
+
+	slots := make(chan struct{}, req.Concurrency)
+	var wg sync.WaitGroup
+	for i := range playlist.Entries {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			m.updateEntry(entry, i, func(e *models.BatchEntry) { e.Status = models.JobCanceled })
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			m.runEntry(ctx, entry, i)
+		}(i)
+	}
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		return
+	}
+
+	if !m.setStatus(entry, models.JobUploading) {
+		return
+	}
+
+	if err := m.writeManifest(ctx, entry); err != nil {
+		m.fail(entry, &models.ErrorInfo{
+			Code:    "UPLOAD_FAILED",
+			Message: "Failed to upload batch manifest",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	m.mu.Lock()
+	m.finish(entry, models.JobDone, nil)
+	m.mu.Unlock()
+}
+
+// runEntry downloads one playlist entry through the JobManager and records
+// the outcome on the batch.
+func (m *BatchManager) runEntry(ctx context.Context, entry *batchEntry, i int) {
+	m.mu.Lock()
+	item := entry.batch.Entries[i]
+	req := entry.batch.Request.MergeRequest
+	entryBase := fmt.Sprintf("%s%03d-%s", entry.batch.Prefix, item.Index, unsafeKeyChars.ReplaceAllString(item.ID, "_"))
+	m.mu.Unlock()
+
+	if item.URL == "" {
+		m.updateEntry(entry, i, func(e *models.BatchEntry) {
+			e.Status = models.JobFailed
+			e.Error = &models.ErrorInfo{
+				Code:    "INVALID_INPUT",
+				Message: "Playlist entry has no URL",
+			}
+		})
+		return
+	}
+	req.URL = item.URL
+
+	var job *models.Job
+	for {
+		var err error
+		job, err = m.jobManager.Submit(req)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, ErrPoolFull) {
+			m.updateEntry(entry, i, func(e *models.BatchEntry) {
+				e.Status = models.JobFailed
+				e.Error = &models.ErrorInfo{
+					Code:    "INTERNAL_ERROR",
+					Message: "Failed to queue entry",
+					Details: err.Error(),
+				}
+			})
+			return
+		}
+
+		select {
+		case <-time.After(batchRetryDelay):
+		case <-ctx.Done():
+			m.updateEntry(entry, i, func(e *models.BatchEntry) { e.Status = models.JobCanceled })
+			return
+		}
+	}
+
+	m.updateEntry(entry, i, func(e *models.BatchEntry) {
+		e.JobID = job.ID
+		e.Status = models.JobRunning
+	})
+
+	finished, err := m.jobManager.Wait(ctx, job.ID)
+	if ctx.Err() != nil {
+		if _, err := m.jobManager.Cancel(job.ID, job.CancelToken); err != nil && !errors.Is(err, ErrJobFinished) {
+			log.Printf(" Failed to cancel job %s of batch %s: %v", job.ID, entry.batch.ID, err)
+		}
+		m.updateEntry(entry, i, func(e *models.BatchEntry) { e.Status = models.JobCanceled })
+		return
+	}
+	if err != nil {
+		m.updateEntry(entry, i, func(e *models.BatchEntry) {
+			e.Status = models.JobFailed
+			e.Error = &models.ErrorInfo{
+				Code:    "INTERNAL_ERROR",
+				Message: "Failed to read entry job",
+				Details: err.Error(),
+			}
+		})
+		return
+	}
+
+	result := finished.Result
+	if finished.Status == models.JobDone && result != nil {
+		if result, err = m.copyResult(ctx, result, entryBase); err != nil {
+			m.updateEntry(entry, i, func(e *models.BatchEntry) {
+				e.Status = models.JobFailed
+				e.Error = &models.ErrorInfo{
+					Code:    "UPLOAD_FAILED",
+					Message: "Failed to copy entry into the playlist",
+					Details: err.Error(),
+				}
+			})
+			return
+		}
+	}
+
+	m.updateEntry(entry, i, func(e *models.BatchEntry) {
+		e.Status = finished.Status
+		e.Result = result
+		e.Error = finished.Error
+	})
+}
+
+// copyResult copies the objects of a finished entry job, which may be shared
+// with other jobs, to keys under entryBase, so the playlist keeps its own
+// files and their retention starts with the batch.
+func (m *BatchManager) copyResult(ctx context.Context, result *models.MergeResult, entryBase string) (*models.MergeResult, error) {
+	copied := *result
+	var err error
+
+	if result.Key != "" {
+		copied.Key = entryBase + path.Ext(result.Key)
+		if copied.URL, err = m.copyObject(ctx, result.Key, copied.Key); err != nil {
+			return nil, err
+		}
+	}
+
+	copied.Subtitles = make([]models.SubtitleFile, len(result.Subtitles))
+	for i, sub := range result.Subtitles {
+		sub.Key = entryBase + "." + sub.Lang + ".vtt"
+		if sub.URL, err = m.copyObject(ctx, result.Subtitles[i].Key, sub.Key); err != nil {
+			return nil, err
+		}
+		copied.Subtitles[i] = sub
+	}
+
+	copied.Chapters = make([]models.ChapterFile, len(result.Chapters))
+	for i, chapter := range result.Chapters {
+		chapter.Key = fmt.Sprintf("%s.chapter-%03d%s", entryBase, chapter.Index, path.Ext(chapter.Key))
+		if chapter.URL, err = m.copyObject(ctx, result.Chapters[i].Key, chapter.Key); err != nil {
+			return nil, err
+		}
+		copied.Chapters[i] = chapter
+	}
+
+	return &copied, nil
+}
+
+func (m *BatchManager) copyObject(ctx context.Context, srcKey, destKey string) (string, error) {
+	if copier, ok := m.storage.(ObjectCopier); ok {
+		return copier.Copy(ctx, srcKey, destKey)
+	}
+
+	if err := os.MkdirAll(m.tmpDir, 0755); err != nil {
+		return "", err
+	}
+	localPath := filepath.Join(m.tmpDir, uuid.New().String()+".copy")
+	defer os.Remove(localPath)
+
+	if err := m.storage.Download(ctx, srcKey, localPath); err != nil {
+		return "", err
+	}
+	return m.storage.Upload(ctx, localPath, destKey, map[string]string{})
+}
+
+// writeManifest uploads the ZIP archive, if requested, followed by the JSON
+// manifest, which includes the archive's URL.
+func (m *BatchManager) writeManifest(ctx context.Context, entry *batchEntry) error {
+	if err := os.MkdirAll(m.tmpDir, 0755); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	batch := entry.snapshot()
+	m.mu.Unlock()
+	batch.Status = models.JobDone
+
+	if batch.Request.Zip {
+		zipURL, err := m.writeZip(ctx, batch)
+		if err != nil {
+			return fmt.Errorf("failed to create zip archive: %w", err)
+		}
+		batch.ZipURL = zipURL
+		m.mu.Lock()
+		entry.batch.ZipURL = zipURL
+		m.mu.Unlock()
+	}
+
+	manifestPath := filepath.Join(m.tmpDir, batch.ID+".manifest.json")
+	defer os.Remove(manifestPath)
+	if err := writeJSONFile(manifestPath, batch); err != nil {
+		return err
+	}
+
+	manifestURL, err := m.storage.Upload(ctx, manifestPath, batch.Prefix+"manifest.json", map[string]string{})
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	entry.batch.ManifestURL = manifestURL
+	m.mu.Unlock()
+	return nil
+}
+
+// writeZip bundles every downloaded entry with the manifest and stores it as
+// playlist.zip. Backends that take streams receive the archive as it is
+// written, so only one entry at a time is staged on disk; for others the
+// whole archive is staged first.
+func (m *BatchManager) writeZip(ctx context.Context, batch *models.Batch) (string, error) {
+	key := batch.Prefix + "playlist.zip"
+
+	uploader, ok := m.storage.(StreamUploader)
+	if !ok {
+		zipPath := filepath.Join(m.tmpDir, batch.ID+".zip")
+		defer os.Remove(zipPath)
+
+		f, err := os.Create(zipPath)
+		if err != nil {
+			return "", err
+		}
+		if err := m.buildZip(ctx, f, batch); err != nil {
+			f.Close()
+			return "", err
+		}
+		if err := f.Close(); err != nil {
+			return "", err
+		}
+		return m.storage.Upload(ctx, zipPath, key, map[string]string{})
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	pr, pw := io.Pipe()
+	buildErr := make(chan error, 1)
+	go func() {
+		err := m.buildZip(ctx, pw, batch)
+		pw.CloseWithError(err)
+		buildErr <- err
+	}()
+
+	zipURL, err := uploader.UploadStream(ctx, pr, key, ContentType(".zip"), map[string]string{})
+	if err != nil {
+		// Unblock and stop the writer before reporting which side failed.
+		pr.CloseWithError(err)
+		cancel()
+	}
+	if buildErr := <-buildErr; buildErr != nil {
+		return "", buildErr
+	}
+	if err != nil {
+		return "", err
+	}
+	return zipURL, nil
+}
+
+// buildZip writes the archive of batch to w. Media is already compressed, so
+// files are stored rather than deflated.
+func (m *BatchManager) buildZip(ctx context.Context, w io.Writer, batch *models.Batch) error {
+	archive := zip.NewWriter(w)
+	for _, e := range batch.Entries {
+		if e.Status != models.JobDone || e.Result == nil {
+			continue
+		}
+
+		name := fmt.Sprintf("%03d - %s", e.Index, archiveName(e.Title, e.ID))
+		if e.Result.Key != "" {
+			if err := m.addToZip(ctx, archive, name+path.Ext(e.Result.Key), e.Result.Key, batch.ID); err != nil {
+				return fmt.Errorf("entry %d: %w", e.Index, err)
+			}
+		}
+		// Split entries become a folder of their chapters.
+		for _, chapter := range e.Result.Chapters {
+			chapterName := fmt.Sprintf("%s/%03d - %s%s", name, chapter.Index, archiveName(chapter.Title, strconv.Itoa(chapter.Index)), path.Ext(chapter.Key))
+			if err := m.addToZip(ctx, archive, chapterName, chapter.Key, batch.ID); err != nil {
+				return fmt.Errorf("entry %d chapter %d: %w", e.Index, chapter.Index, err)
+			}
+		}
+	}
+
+	manifest, err := archive.Create("manifest.json")
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(manifest)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(batch); err != nil {
+		return err
+	}
+
+	return archive.Close()
+}
+
+func (m *BatchManager) addToZip(ctx context.Context, archive *zip.Writer, name, key, batchID string) error {
+	localPath := filepath.Join(m.tmpDir, batchID+".entry")
+	defer os.Remove(localPath)
+
+	if err := m.storage.Download(ctx, key, localPath); err != nil {
+		return err
+	}
+
+	src, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	w, err := archive.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Store,
+		Modified: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, src)
+	return err
+}
+
+// archiveName makes a title usable as a file name, falling back to id.
+func archiveName(title, id string) string {
+	name := strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f || strings.ContainsRune(`/\:*?"<>|`, r) {
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(title))
+	if name == "" {
+		name = id
+	}
+	if runes := []rune(name); len(runes) > 120 {
+		name = string(runes[:120])
+	}
+	return name
+}
+
+func writeJSONFile(path string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+func (m *BatchManager) updateEntry(entry *batchEntry, i int, update func(*models.BatchEntry)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	update(&entry.batch.Entries[i])
+
+	succeeded, failed := 0, 0
+	for _, e := range entry.batch.Entries {
+		switch e.Status {
+		case models.JobDone:
+			succeeded++
+		case models.JobFailed:
+			failed++
+		}
+	}
+	entry.batch.Succeeded = succeeded
+	entry.batch.Failed = failed
+	entry.batch.UpdatedAt = time.Now().Unix()
+}
+
+// setStatus moves an unfinished batch to status, reporting false if it was
+// already finished.
+func (m *BatchManager) setStatus(entry *batchEntry, status models.JobStatus) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if entry.batch.Status.Finished() {
+		return false
+	}
+	entry.batch.Status = status
+	entry.batch.UpdatedAt = time.Now().Unix()
+	return true
+}
+
+func (m *BatchManager) fail(entry *batchEntry, info *models.ErrorInfo) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.finish(entry, models.JobFailed, info)
+}
+
+// finish must be called with m.mu held.
+func (m *BatchManager) finish(entry *batchEntry, status models.JobStatus, info *models.ErrorInfo) {
+	if entry.batch.Status.Finished() {
+		return
+	}
+
+	entry.batch.Status = status
+	entry.batch.Error = info
+	entry.batch.UpdatedAt = time.Now().Unix()
+
+	id := entry.batch.ID
+	time.AfterFunc(jobRetention, func() {
+		m.mu.Lock()
+		delete(m.batches, id)
+		m.mu.Unlock()
+	})
+}
+
+// snapshot copies the batch for use outside the lock. It must be called with
+// BatchManager.mu held.
+func (e *batchEntry) snapshot() *models.Batch {
+	batch := e.batch
+	batch.Entries = make([]models.BatchEntry, len(e.batch.Entries))
+	copy(batch.Entries, e.batch.Entries)
+	return &batch
+}
@@ -0,0 +1,228 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/pavelc4/ytdpl-api-go/internal/models"
+)
+
+const maxChapterLength = 256
+
+var (
+	ErrChapterNotFound = fmt.Errorf("%w: chapter not found", ErrInvalidRequest)
+	ErrNoChapters      = fmt.Errorf("%w: video has no chapters", ErrInvalidRequest)
+)
+
+var chapterFilePattern = regexp.MustCompile(`\.chapter\.([0-9]+)\.[^.]+$`)
+
+// parseClipTime parses seconds ("90", "90.5") or "[hh:]mm:ss[.ms]".
+func parseClipTime(s string) (float64, bool) {
+	seconds, ok := parseVTTTimestamp(s)
+	if !strings.Contains(s, ":") {
+		var err error
+		seconds, err = strconv.ParseFloat(s, 64)
+		ok = err == nil && seconds >= 0
+	}
+	if !ok || math.IsInf(seconds, 0) || math.IsNaN(seconds) {
+		return 0, false
+	}
+	return seconds, true
+}
+
+func validateClip(req models.MergeRequest) error {
+	clipped := req.Start != "" || req.End != "" || req.Chapter != ""
+
+	if req.Chapter != "" && (req.Start != "" || req.End != "") {
+		return fmt.Errorf("%w: set either chapter or start/end, not both", ErrInvalidRequest)
+	}
+
+	var start, end float64
+	var ok bool
+	if req.Start != "" {
+		if start, ok = parseClipTime(req.Start); !ok {
+			return fmt.Errorf("%w: invalid start %q, use seconds or [hh:]mm:ss", ErrInvalidRequest, req.Start)
+		}
+	}
+	if req.End != "" {
+		if end, ok = parseClipTime(req.End); !ok {
+			return fmt.Errorf("%w: invalid end %q, use seconds or [hh:]mm:ss", ErrInvalidRequest, req.End)
+		}
+		if end <= start {
+			return fmt.Errorf("%w: end must be after start", ErrInvalidRequest)
+		}
+	}
+
+	if len(req.Chapter) > maxChapterLength || strings.ContainsFunc(req.Chapter, unicode.IsControl) {
+		return fmt.Errorf("%w: invalid chapter", ErrInvalidRequest)
+	}
+
+	if req.SplitChapters {
+		if clipped {
+			return fmt.Errorf("%w: split_chapters cannot be combined with start, end or chapter", ErrInvalidRequest)
+		}
+		if req.Conversion == conversionTranscode || req.Tags != nil || len(req.Subtitles) > 0 {
+			return fmt.Errorf("%w: split_chapters cannot be combined with transcoding, tags or subtitles", ErrInvalidRequest)
+		}
+	}
+	if req.ForceKeyframes && !clipped && !req.SplitChapters {
+		return fmt.Errorf("%w: force_keyframes requires start, end, chapter or split_chapters", ErrInvalidRequest)
+	}
+	return nil
+}
+
+// clips reports whether req downloads part of the video or splits it.
+func clips(req models.MergeRequest) bool {
+	return req.Start != "" || req.End != "" || req.Chapter != "" || req.SplitChapters
+}
+
+// clipArgs resolves the clip of req to --download-sections times, or asks
+// yt-dlp to split the output by chapter into files next to outputBase.
+// Chapters are looked up first so unknown ones fail before downloading.
+func (s *YTDLPService) clipArgs(ctx context.Context, req models.MergeRequest, outputBase string) ([]string, error) {
+	if !clips(req) {
+		return nil, nil
+	}
+
+	var args []string
+	switch {
+	case req.SplitChapters:
+		chapters, err := s.chapters(ctx, req.URL)
+		if err != nil {
+			return nil, err
+		}
+		if len(chapters) == 0 {
+			return nil, ErrNoChapters
+		}
+		args = append(args, "--split-chapters", "-o", "chapter:"+outputBase+".chapter.%(section_number)03d.%(ext)s")
+	case req.Chapter != "":
+		chapters, err := s.chapters(ctx, req.URL)
+		if err != nil {
+			return nil, err
+		}
+		chapter, ok := findChapter(chapters, req.Chapter)
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", ErrChapterNotFound, req.Chapter)
+		}
+		args = append(args, "--download-sections", clipSection(chapter.StartTime, chapter.EndTime))
+	default:
+		start, _ := parseClipTime(req.Start)
+		end := -1.0
+		if req.End != "" {
+			end, _ = parseClipTime(req.End)
+		}
+		args = append(args, "--download-sections", clipSection(start, end))
+	}
+
+	if req.ForceKeyframes {
+		args = append(args, "--force-keyframes-at-cuts")
+	}
+	return args, nil
+}
+
+func (s *YTDLPService) chapters(ctx context.Context, url string) ([]models.Chapter, error) {
+	data, err := s.Extract(ctx, url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract chapters: %w", err)
+	}
+	return data.Chapters, nil
+}
+
+// findChapter looks chapter up by 1-based index or, case-insensitively, by
+// title.
+func findChapter(chapters []models.Chapter, spec string) (models.Chapter, bool) {
+	spec = strings.TrimSpace(spec)
+	if index, err := strconv.Atoi(spec); err == nil {
+		if index < 1 || index > len(chapters) {
+			return models.Chapter{}, false
+		}
+		return chapters[index-1], true
+	}
+	for _, chapter := range chapters {
+		if strings.EqualFold(strings.TrimSpace(chapter.Title), spec) {
+			return chapter, true
+		}
+	}
+	return models.Chapter{}, false
+}
+
+// clipSection formats a --download-sections time range; a negative end
+// means to the end of the video.
+func clipSection(start, end float64) string {
+	to := "inf"
+	if end >= 0 {
+		to = strconv.FormatFloat(end, 'f', -1, 64)
+	}
+	return "*" + strconv.FormatFloat(start, 'f', -1, 64) + "-" + to
+}
+
+// findChapterFiles returns the per-chapter files yt-dlp split next to
+// outputBase, keyed by 1-based chapter index.
+func findChapterFiles(outputBase string) map[int]string {
+	matches, _ := filepath.Glob(outputBase + ".chapter.*")
+	files := make(map[int]string, len(matches))
+	for _, match := range matches {
+		m := chapterFilePattern.FindStringSubmatch(match)
+		if m == nil {
+			continue
+		}
+		index, _ := strconv.Atoi(m[1])
+		files[index] = match
+	}
+	return files
+}
+
+// uploadChapters stores the split chapters of a job as
+// <key without extension>.chapter-NNN.<ext>.
+func (m *JobManager) uploadChapters(ctx context.Context, req models.MergeRequest, outputBase, objectKey string) ([]models.ChapterFile, error) {
+	files := findChapterFiles(outputBase)
+	if len(files) == 0 {
+		return nil, errors.New("yt-dlp produced no chapter files")
+	}
+
+	chapters, err := m.ytdlpService.chapters(ctx, req.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	indexes := make([]int, 0, len(files))
+	for index := range files {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+	for _, index := range indexes {
+		if index < 1 || index > len(chapters) {
+			return nil, fmt.Errorf("%w: yt-dlp wrote chapter %d, the video has %d", ErrChapterNotFound, index, len(chapters))
+		}
+	}
+
+	base := strings.TrimSuffix(objectKey, filepath.Ext(objectKey))
+	uploaded := make([]models.ChapterFile, 0, len(indexes))
+	for _, index := range indexes {
+		path := files[index]
+		key := fmt.Sprintf("%s.chapter-%03d%s", base, index, filepath.Ext(path))
+		publicURL, err := m.storage.Upload(ctx, path, key, map[string]string{})
+		if err != nil {
+			return nil, err
+		}
+
+		chapter := chapters[index-1]
+		uploaded = append(uploaded, models.ChapterFile{
+			Index: index,
+			Title: chapter.Title,
+			Start: chapter.StartTime,
+			End:   chapter.EndTime,
+			URL:   publicURL,
+			Key:   key,
+		})
+	}
+	return uploaded, nil
+}
@@ -0,0 +1,85 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pavelc4/ytdpl-api-go/internal/cache"
+	"github.com/pavelc4/ytdpl-api-go/internal/models"
+)
+
+const clipTestURL = "https://www.youtube.com/watch?v=dQw4w9WgXcQ"
+
+// newChapterJobManager returns a JobManager on memory storage whose cached
+// extraction of clipTestURL lists chapters, so no yt-dlp process is needed.
+func newChapterJobManager(t *testing.T, chapters []models.Chapter) *JobManager {
+	t.Helper()
+	c := cache.NewMemoryCache()
+	s := NewYTDLPService("", nil, c)
+	data := models.YTDLPOutput{ID: "dQw4w9WgXcQ", Chapters: chapters}
+	if err := c.Set(context.Background(), "json_"+s.CacheKey(clipTestURL), &data, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	return &JobManager{ytdlpService: s, storage: NewMemoryStorage()}
+}
+
+func writeChapterFiles(t *testing.T, outputBase string, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		if err := os.WriteFile(outputBase+name, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestUploadChapters(t *testing.T) {
+	chapters := []models.Chapter{
+		{Title: "Intro", StartTime: 0, EndTime: 10},
+		{Title: "Main", StartTime: 10, EndTime: 60},
+	}
+	m := newChapterJobManager(t, chapters)
+	outputBase := filepath.Join(t.TempDir(), "job")
+	writeChapterFiles(t, outputBase, ".chapter.002.mp4", ".chapter.001.mp4", ".mp4", ".chapter.x.mp4")
+
+	got, err := m.uploadChapters(context.Background(), models.MergeRequest{URL: clipTestURL}, outputBase, "vidioe/abc.mp4")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []models.ChapterFile{
+		{Index: 1, Title: "Intro", Start: 0, End: 10, URL: "memory://vidioe/abc.chapter-001.mp4", Key: "vidioe/abc.chapter-001.mp4"},
+		{Index: 2, Title: "Main", Start: 10, End: 60, URL: "memory://vidioe/abc.chapter-002.mp4", Key: "vidioe/abc.chapter-002.mp4"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("uploadChapters() = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("chapter %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestUploadChaptersRejectsBadIndexes(t *testing.T) {
+	chapters := []models.Chapter{{Title: "Only", StartTime: 0, EndTime: 10}}
+
+	for _, name := range []string{".chapter.000.mp4", ".chapter.002.mp4"} {
+		t.Run(name, func(t *testing.T) {
+			m := newChapterJobManager(t, chapters)
+			outputBase := filepath.Join(t.TempDir(), "job")
+			writeChapterFiles(t, outputBase, ".chapter.001.mp4", name)
+
+			_, err := m.uploadChapters(context.Background(), models.MergeRequest{URL: clipTestURL}, outputBase, "vidioe/abc.mp4")
+			if !errors.Is(err, ErrChapterNotFound) {
+				t.Fatalf("uploadChapters() = %v, want ErrChapterNotFound", err)
+			}
+			if objects, _ := m.storage.List(context.Background(), "vidioe/"); len(objects) != 0 {
+				t.Fatalf("uploaded %d chapters before failing", len(objects))
+			}
+		})
+	}
+}
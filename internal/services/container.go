@@ -0,0 +1,257 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pavelc4/ytdpl-api-go/internal/models"
+)
+
+// Conversion modes for streams that do not fit the requested container.
+const (
+	// conversionRemux copies the streams into the container regardless,
+	// leaving it to ffmpeg and the player to cope.
+	conversionRemux = "remux"
+	// conversionTranscode re-encodes only the streams that do not fit.
+	conversionTranscode = "transcode"
+)
+
+// codecRule describes which codecs of one stream kind a container accepts
+// and how to re-encode the others.
+type codecRule struct {
+	codecs  []string
+	encoder string
+}
+
+func (r *codecRule) accepts(codec string) bool {
+	for _, prefix := range r.codecs {
+		if strings.HasPrefix(codec, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// filter narrows a yt-dlp format selector term to the accepted codecs.
+func (r *codecRule) filter(field string) string {
+	return fmt.Sprintf("[%s~='^(%s)']", field, strings.Join(r.codecs, "|"))
+}
+
+type containerRules struct {
+	video *codecRule
+	audio *codecRule
+}
+
+var (
+	mp4Rules = containerRules{
+		video: &codecRule{codecs: []string{"avc1", "h264", "hvc1", "hev1", "av01"}, encoder: "libx264"},
+		audio: &codecRule{codecs: []string{"mp4a", "aac", "mp3"}, encoder: "aac"},
+	}
+	webmRules = containerRules{
+		video: &codecRule{codecs: []string{"vp8", "vp9", "vp09", "av01"}, encoder: "libvpx-vp9"},
+		audio: &codecRule{codecs: []string{"opus", "vorbis"}, encoder: "libopus"},
+	}
+)
+
+// videoContainers are the containers a video merge may produce. mkv takes
+// any codec, so it has no rules.
+var videoContainers = map[string]containerRules{
+	"mp4":  mp4Rules,
+	"mov":  mp4Rules,
+	"webm": webmRules,
+	"mkv":  {},
+}
+
+func validateContainer(req models.MergeRequest) error {
+	if req.Type != "video" {
+		if req.Conversion != "" {
+			return fmt.Errorf("%w: conversion requires type=video", ErrInvalidRequest)
+		}
+		return nil
+	}
+	if _, ok := videoContainers[req.Format]; !ok {
+		return fmt.Errorf("%w: unsupported container %q, use one of %s", ErrInvalidRequest, req.Format, strings.Join(videoContainerNames(), ", "))
+	}
+	switch req.Conversion {
+	case "", conversionRemux, conversionTranscode:
+		return nil
+	default:
+		return fmt.Errorf("%w: conversion must be %s or %s", ErrInvalidRequest, conversionRemux, conversionTranscode)
+	}
+}
+
+// checksCodecs reports whether the formats picked for req must be checked
+// against its container before downloading.
+func checksCodecs(req models.MergeRequest) bool {
+	if req.Type != "video" || req.Conversion == conversionRemux {
+		return false
+	}
+	rules := videoContainers[req.Format]
+	return rules.video != nil || rules.audio != nil
+}
+
+// compatibleSelector narrows the quality preset of req to codecs its
+// container accepts, or returns "" when there is nothing to narrow. The
+// result is tried before the plain preset, so a video without compatible
+// streams still resolves and is reported by checkCodecs.
+func compatibleSelector(req models.MergeRequest) string {
+	rules := videoContainers[req.Format]
+	if req.Type != "video" || req.Conversion == conversionRemux || rules.video == nil {
+		return ""
+	}
+	v, a := rules.video.filter("vcodec"), rules.audio.filter("acodec")
+
+	switch req.Quality {
+	case "best":
+		return fmt.Sprintf("bestvideo%s+bestaudio%s/best%s%s", v, a, v, a)
+	case "smallest":
+		return fmt.Sprintf("worstvideo%s+worstaudio%s/worst%s%s", v, a, v, a)
+	case "av1-preferred":
+		return fmt.Sprintf("bestvideo[vcodec^=av01]+bestaudio%s/bestvideo%s+bestaudio%s/best%s%s", a, v, a, v, a)
+	}
+	if height, ok := presetHeights[req.Quality]; ok {
+		h := fmt.Sprintf("[height<=%d]", height)
+		return fmt.Sprintf("bestvideo%s%s+bestaudio%s/best%s%s%s", h, v, a, h, v, a)
+	}
+	return ""
+}
+
+// codecMismatch lists the streams among formats that req's container does
+// not accept, as "video" and/or "audio" mapped to the offending codec.
+func codecMismatch(req models.MergeRequest, formats []streamFormat) map[string]string {
+	rules := videoContainers[req.Format]
+	mismatch := make(map[string]string)
+	for _, f := range formats {
+		if rules.video != nil && hasCodec(f.VCodec) && !rules.video.accepts(f.VCodec) {
+			mismatch["video"] = f.VCodec
+		}
+		if rules.audio != nil && hasCodec(f.ACodec) && !rules.audio.accepts(f.ACodec) {
+			mismatch["audio"] = f.ACodec
+		}
+	}
+	return mismatch
+}
+
+func hasCodec(codec string) bool {
+	return codec != "" && codec != "none"
+}
+
+func codecError(req models.MergeRequest, mismatch map[string]string) error {
+	rules := videoContainers[req.Format]
+	var found []string
+	for _, kind := range []string{"video", "audio"} {
+		if codec, ok := mismatch[kind]; ok {
+			found = append(found, fmt.Sprintf("%s %s", kind, codec))
+		}
+	}
+	return fmt.Errorf("%w: %s cannot be stored in %s, which accepts %s video and %s audio; use format=mkv, conversion=remux or conversion=transcode",
+		ErrInvalidRequest, strings.Join(found, " and "), req.Format,
+		strings.Join(rules.video.codecs, ", "), strings.Join(rules.audio.codecs, ", "))
+}
+
+// resolveFormats asks yt-dlp which formats req selects without downloading
+// them. It returns the raw -J output, which --load-info-json can replay, and
+// the parsed selection.
+func (s *YTDLPService) resolveFormats(ctx context.Context, req models.MergeRequest) ([]byte, []streamFormat, error) {
+	args := []string{
+		"-J",
+		"-f", formatSelector(req),
+		"--no-playlist",
+		"--no-warnings",
+		"--no-cache-dir",
+	}
+
+	if path, err := exec.LookPath("bun"); err == nil && path != "" {
+		args = append(args, "--js-runtimes", "bun")
+	}
+
+	if s.cookiePath != "" {
+		args = append(args, "--cookies", s.cookiePath)
+	}
+
+	args = append(args, req.URL)
+
+	output, err := s.run(ctx, args)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to resolve formats: %w (output: %s)", err, string(output))
+	}
+
+	var info streamInfo
+	if err := json.Unmarshal(output, &info); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse JSON: %w", err)
+	}
+
+	formats := info.RequestedFormats
+	if len(formats) == 0 {
+		formats = []streamFormat{info.streamFormat}
+	}
+	return output, formats, nil
+}
+
+// transcode re-encodes the streams of the file at path that do not fit
+// req's container, copying the rest, and returns the path of the result,
+// which replaces the input.
+func (s *YTDLPService) transcode(ctx context.Context, path string, req models.MergeRequest, mismatch map[string]string) (string, error) {
+	rules := videoContainers[req.Format]
+	base := strings.TrimSuffix(path, filepath.Ext(path))
+	target := base + ".transcoded." + req.Format
+
+	videoCodec, audioCodec := "copy", "copy"
+	if _, ok := mismatch["video"]; ok {
+		videoCodec = rules.video.encoder
+	}
+	if _, ok := mismatch["audio"]; ok {
+		audioCodec = rules.audio.encoder
+	}
+
+	args := []string{
+		"-hide_banner", "-loglevel", "error", "-y",
+		"-i", path,
+		"-map", "0:v?", "-map", "0:a?",
+		"-c:v", videoCodec, "-c:a", audioCodec,
+	}
+	if embedsSubtitles(req) {
+		args = append(args, "-map", "0:s?", "-c:s", subtitleCodecs[req.Format])
+	}
+	if req.Format == "mp4" || req.Format == "mov" {
+		args = append(args, "-movflags", "+faststart")
+	}
+	args = append(args, target)
+
+	err := s.pool.Do(ctx, func() error {
+		var stderr bytes.Buffer
+		cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("failed to transcode: %w (output: %s)", err, stderr.String())
+		}
+		return nil
+	})
+	if err != nil {
+		os.Remove(target)
+		return "", err
+	}
+	os.Remove(path)
+
+	output := base + "." + req.Format
+	if err := os.Rename(target, output); err != nil {
+		return "", err
+	}
+	return output, nil
+}
+
+func videoContainerNames() []string {
+	names := make([]string, 0, len(videoContainers))
+	for name := range videoContainers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,114 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/pavelc4/ytdpl-api-go/internal/models"
+)
+
+// Metadata keys stored with every merged object.
+const (
+	metaCanonicalID    = "canonical-id"
+	metaFormatSelector = "format-selector"
+	metaContainer      = "container"
+	metaContentHash    = "sha256"
+)
+
+// outputOptions encodes the options of req that change the produced file
+// beyond its formats and container, for use in keys. It is empty for
+// defaults so keys of plain merges stay stable.
+func outputOptions(req models.MergeRequest) string {
+	var options string
+	if req.Conversion != "" {
+		options += "\nconversion=" + req.Conversion
+	}
+	if req.Start != "" || req.End != "" {
+		options += "\nsection=" + req.Start + "-" + req.End
+	}
+	if req.Chapter != "" {
+		options += "\nchapter=" + req.Chapter
+	}
+	if req.ForceKeyframes {
+		options += "\nforce-keyframes"
+	}
+	if req.SplitChapters {
+		options += "\nsplit-chapters"
+	}
+	if len(req.Subtitles) > 0 {
+		options += "\nsubtitles=" + strings.Join(req.Subtitles, ",") + "\nsubtitle-mode=" + req.SubtitleMode
+		if req.AutoSubtitles {
+			options += "\nauto-subtitles"
+		}
+	}
+	if req.Type == "audio" {
+		// aac and m4a share an extension, as do original and its codec.
+		if req.Format != audioFormats[req.Format] {
+			options += "\naudio-format=" + req.Format
+		}
+		if req.AudioQuality != "" {
+			options += "\naudio-quality=" + req.AudioQuality
+		}
+		if req.EmbedMetadata || req.Tags != nil {
+			options += "\nembed-metadata"
+		}
+		if req.EmbedThumbnail {
+			options += "\nembed-thumbnail"
+		}
+		if req.Tags != nil {
+			for _, tag := range req.Tags.Fields() {
+				options += "\ntag-" + tag.Name + "=" + tag.Value
+			}
+		}
+	}
+	return options
+}
+
+// objectKey derives a content-addressed key from the canonical video ID, the
+// format selector and the container, so repeated merges of the same video
+// resolve to the same object. If the video cannot be identified a random key
+// is used and no deduplication takes place.
+func (m *JobManager) objectKey(ctx context.Context, job models.Job, ext string) (string, map[string]string) {
+	req := job.Request
+	folder := "vidioe"
+	if req.Type == "audio" {
+		folder = "audio"
+	}
+
+	canonicalID, err := m.ytdlpService.CanonicalID(ctx, req.URL)
+	if err != nil {
+		log.Printf(" Failed to identify %s, skipping deduplication: %v", req.URL, err)
+		return fmt.Sprintf("%s/%s.%s", folder, uuid.New().String(), ext), map[string]string{}
+	}
+
+	selector := formatSelector(req)
+	sum := sha256.Sum256([]byte(canonicalID + "\n" + selector + "\n" + ext + outputOptions(req)))
+	key := fmt.Sprintf("%s/%s.%s", folder, hex.EncodeToString(sum[:16]), ext)
+
+	return key, map[string]string{
+		metaCanonicalID:    canonicalID,
+		metaFormatSelector: selector,
+		metaContainer:      ext,
+	}
+}
+
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
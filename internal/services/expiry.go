@@ -0,0 +1,66 @@
+package services
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pavelc4/ytdpl-api-go/internal/models"
+)
+
+// urlExpiry reads the expiry signed into a direct media URL. googlevideo
+// URLs carry it as an expire= query parameter, or as an /expire/<unix>/ path
+// segment in manifest URLs.
+func urlExpiry(rawURL string) (time.Time, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	value := u.Query().Get("expire")
+	if value == "" {
+		segments := strings.Split(u.Path, "/")
+		for i := 0; i+1 < len(segments); i++ {
+			if segments[i] == "expire" {
+				value = segments[i+1]
+				break
+			}
+		}
+	}
+
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || seconds <= 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(seconds, 0), true
+}
+
+// extractionExpiry returns the earliest expiry among the selected direct URLs
+// of data, if any of them carries one.
+func extractionExpiry(data *models.YTDLPOutput) (time.Time, bool) {
+	urls := []string{data.URL}
+	for _, f := range data.RequestedFormats {
+		urls = append(urls, f.URL)
+	}
+
+	var earliest time.Time
+	found := false
+	for _, u := range urls {
+		if u == "" {
+			continue
+		}
+		if t, ok := urlExpiry(u); ok && (!found || t.Before(earliest)) {
+			earliest = t
+			found = true
+		}
+	}
+	return earliest, found
+}
+
+// freshExtraction reports whether yt-dlp produced data within the last
+// freshExtractionAge, e.g. because the cache missed, so extracting again
+// would yield the same URLs.
+func freshExtraction(data *models.YTDLPOutput) bool {
+	return data.Epoch > 0 && time.Since(time.Unix(data.Epoch, 0)) < freshExtractionAge
+}
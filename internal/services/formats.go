@@ -0,0 +1,135 @@
+package services
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/pavelc4/ytdpl-api-go/internal/models"
+)
+
+// FormatFilter narrows and orders the list returned by /formats. Zero values
+// disable the corresponding filter.
+type FormatFilter struct {
+	// Kind is video (video only), audio (audio only) or muxed.
+	Kind      string
+	MaxHeight int
+	// VCodec matches codec prefixes, e.g. avc1 or vp9; h264, h265 and av1
+	// are accepted as aliases.
+	VCodec string
+	Ext    string
+	// Sort names a numeric field, prefixed with - for descending order.
+	// Without it formats keep yt-dlp's worst-to-best order.
+	Sort string
+}
+
+var formatKinds = map[string]bool{"video": true, "audio": true, "muxed": true}
+
+var vcodecAliases = map[string][]string{
+	"h264": {"avc1", "h264"},
+	"h265": {"hvc1", "hev1", "h265"},
+	"hevc": {"hvc1", "hev1", "h265"},
+	"av1":  {"av01"},
+}
+
+var formatSortKeys = map[string]func(f *models.VideoFormat) float64{
+	"quality":  func(f *models.VideoFormat) float64 { return f.Quality },
+	"height":   func(f *models.VideoFormat) float64 { return float64(f.Height) },
+	"width":    func(f *models.VideoFormat) float64 { return float64(f.Width) },
+	"fps":      func(f *models.VideoFormat) float64 { return f.FPS },
+	"tbr":      func(f *models.VideoFormat) float64 { return f.TBR },
+	"abr":      func(f *models.VideoFormat) float64 { return f.ABR },
+	"vbr":      func(f *models.VideoFormat) float64 { return f.VBR },
+	"asr":      func(f *models.VideoFormat) float64 { return float64(f.ASR) },
+	"filesize": func(f *models.VideoFormat) float64 { return float64(formatSize(f)) },
+}
+
+// Validate reports the first invalid parameter in f.
+func (f FormatFilter) Validate() error {
+	if f.Kind != "" && !formatKinds[f.Kind] {
+		return fmt.Errorf("kind must be one of video, audio, muxed")
+	}
+	if f.MaxHeight < 0 {
+		return fmt.Errorf("max_height must not be negative")
+	}
+	if f.Sort != "" {
+		if _, ok := formatSortKeys[strings.TrimPrefix(f.Sort, "-")]; !ok {
+			return fmt.Errorf("unknown sort field %q", f.Sort)
+		}
+	}
+	return nil
+}
+
+// Apply returns the formats matching f in the requested order. formats is
+// not modified.
+func (f FormatFilter) Apply(formats []models.VideoFormat) []models.VideoFormat {
+	result := make([]models.VideoFormat, 0, len(formats))
+	for _, format := range formats {
+		format.Kind = formatKind(&format)
+		if f.matches(&format) {
+			result = append(result, format)
+		}
+	}
+
+	if f.Sort != "" {
+		field, descending := strings.CutPrefix(f.Sort, "-")
+		key := formatSortKeys[field]
+		slices.SortStableFunc(result, func(a, b models.VideoFormat) int {
+			if descending {
+				return cmp.Compare(key(&b), key(&a))
+			}
+			return cmp.Compare(key(&a), key(&b))
+		})
+	}
+	return result
+}
+
+func (f FormatFilter) matches(format *models.VideoFormat) bool {
+	if f.Kind != "" && format.Kind != f.Kind {
+		return false
+	}
+	if f.MaxHeight > 0 && format.Height > f.MaxHeight {
+		return false
+	}
+	if f.Ext != "" && !strings.EqualFold(format.Ext, f.Ext) {
+		return false
+	}
+	if f.VCodec != "" {
+		want := strings.ToLower(f.VCodec)
+		prefixes, ok := vcodecAliases[want]
+		if !ok {
+			prefixes = []string{want}
+		}
+		vcodec := strings.ToLower(format.VCodec)
+		if !slices.ContainsFunc(prefixes, func(p string) bool { return strings.HasPrefix(vcodec, p) }) {
+			return false
+		}
+	}
+	return true
+}
+
+// formatKind classifies a format by its streams. yt-dlp reports "none" for
+// a missing stream; an empty codec means unknown and counts as present.
+func formatKind(f *models.VideoFormat) string {
+	hasVideo := f.VCodec != "none"
+	hasAudio := f.ACodec != "none"
+	switch {
+	case hasVideo && hasAudio:
+		return "muxed"
+	case hasVideo:
+		return "video"
+	case hasAudio:
+		return "audio"
+	default:
+		return "other"
+	}
+}
+
+// formatSize is the exact size when known, otherwise yt-dlp's estimate.
+func formatSize(f *models.VideoFormat) int64 {
+	if f.Filesize > 0 {
+		return f.Filesize
+	}
+	return f.FilesizeApprox
+}
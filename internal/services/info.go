@@ -0,0 +1,74 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/pavelc4/ytdpl-api-go/internal/models"
+)
+
+// infoFields lists every field name VideoInfo can be trimmed to.
+var infoFields = jsonFieldNames(reflect.TypeOf(models.VideoInfo{}))
+
+// ParseInfoFields validates the comma-separated fields parameter of /info.
+// An empty spec or "all" returns nil, meaning every field, and "minimal"
+// selects the basic fields; any unknown name is an error.
+func ParseInfoFields(spec string) ([]string, error) {
+	switch spec = strings.TrimSpace(spec); spec {
+	case "", "all":
+		return nil, nil
+	case "minimal":
+		return models.MinimalInfoFields, nil
+	}
+
+	var fields []string
+	for _, field := range strings.Split(spec, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		if !infoFields[field] {
+			return nil, fmt.Errorf("unknown field %q", field)
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// SelectInfoFields trims info to fields as returned by ParseInfoFields.
+// id is always included.
+func SelectInfoFields(info *models.VideoInfo, fields []string) (any, error) {
+	if fields == nil {
+		return info, nil
+	}
+
+	data, err := json.Marshal(info)
+	if err != nil {
+		return nil, err
+	}
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, err
+	}
+
+	selected := map[string]json.RawMessage{"id": all["id"]}
+	for _, field := range fields {
+		if value, ok := all[field]; ok {
+			selected[field] = value
+		}
+	}
+	return selected, nil
+}
+
+func jsonFieldNames(t reflect.Type) map[string]bool {
+	names := make(map[string]bool, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			names[name] = true
+		}
+	}
+	return names
+}
@@ -0,0 +1,750 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pavelc4/ytdpl-api-go/internal/cache"
+	"github.com/pavelc4/ytdpl-api-go/internal/models"
+	"github.com/pavelc4/ytdpl-api-go/internal/store"
+)
+
+const (
+	mergeTimeout = 15 * time.Minute
+	jobRetention = 24 * time.Hour
+	resultTTL    = 1 * time.Hour
+	// resultIOTimeout bounds cache and store round trips for results.
+	resultIOTimeout = 10 * time.Second
+)
+
+var (
+	ErrJobNotFound        = errors.New("job not found")
+	ErrJobFinished        = errors.New("job already finished")
+	ErrCancelToken        = errors.New("cancel token does not match an active submission")
+	ErrStorageUnavailable = errors.New("storage not configured")
+)
+
+// jobError tags a failure with the API error code it should be reported as.
+type jobError struct {
+	code    string
+	message string
+	err     error
+}
+
+func (e *jobError) Error() string { return e.err.Error() }
+func (e *jobError) Unwrap() error { return e.err }
+
+type jobEntry struct {
+	job         models.Job
+	cancel      context.CancelFunc
+	done        chan struct{}
+	subscribers map[chan models.JobEvent]struct{}
+	// requesters holds the cancel tokens of the submissions coalesced into
+	// this job; each is consumed by one Cancel and the job stops once none
+	// are left.
+	requesters map[string]struct{}
+	resultKey  string
+}
+
+// JobOptions tunes how merge jobs produce and store their output.
+type JobOptions struct {
+	// StreamUploads pipes output into storage when the format allows it.
+	StreamUploads bool
+	// ContentHash records a SHA-256 of each uploaded file in its metadata.
+	ContentHash bool
+}
+
+// JobManager queues merge/upload jobs and runs them on a bounded worker pool.
+// Job state and finished results are written through to a store.Store so they
+// survive restarts; unfinished jobs are picked up again by Resume. Results
+// are also put in the cache so replicas sharing it reuse each other's uploads.
+type JobManager struct {
+	ytdlpService *YTDLPService
+	storage      Storage
+	pool         *WorkerPool
+	store        store.Store
+	cache        cache.Cache
+	tmpDir       string
+	options      JobOptions
+
+	mu   sync.Mutex
+	jobs map[string]*jobEntry
+	// inflight maps result keys to the unfinished job producing them, so
+	// identical requests attach to it instead of downloading again.
+	inflight map[string]string
+}
+
+func NewJobManager(ytdlpService *YTDLPService, storage Storage, pool *WorkerPool, jobStore store.Store, resultCache cache.Cache, options JobOptions) *JobManager {
+	return &JobManager{
+		ytdlpService: ytdlpService,
+		storage:      storage,
+		pool:         pool,
+		store:        jobStore,
+		cache:        resultCache,
+		tmpDir:       filepath.Join(os.TempDir(), "ytdpl"),
+		options:      options,
+		jobs:         make(map[string]*jobEntry),
+		inflight:     make(map[string]string),
+	}
+}
+
+// Resume re-queues jobs that were unfinished when the process last stopped,
+// drops expired job records and removes temp files no resumed job owns.
+// Temp files are named after their job ID, so yt-dlp continues any
+// partial download a resumed job left behind.
+func (m *JobManager) Resume() error {
+	jobs, err := m.store.ListJobs()
+	if err != nil {
+		return fmt.Errorf("failed to list stored jobs: %w", err)
+	}
+
+	resumed := make(map[string]bool)
+	cutoff := time.Now().Add(-jobRetention).Unix()
+
+	for _, job := range jobs {
+		if job.Status.Finished() {
+			if job.UpdatedAt < cutoff {
+				m.store.DeleteJob(job.ID)
+			}
+			continue
+		}
+
+		if m.storage == nil {
+			m.failResumed(*job, &models.ErrorInfo{
+				Code:    "SERVICE_UNAVAILABLE",
+				Message: "Storage not configured",
+				Details: ErrStorageUnavailable.Error(),
+			})
+			continue
+		}
+
+		job.Status = models.JobQueued
+		job.UpdatedAt = time.Now().Unix()
+		if _, err := m.enqueue(*job, m.resultKey(*job), false); err != nil {
+			// Left as is, the job would stay unfinished in the store forever.
+			log.Printf(" Failed to resume job %s: %v", job.ID, err)
+			m.failResumed(*job, &models.ErrorInfo{
+				Code:    "INTERNAL_ERROR",
+				Message: "Failed to resume job after restart",
+				Details: err.Error(),
+			})
+			continue
+		}
+		resumed[job.ID] = true
+	}
+
+	if len(resumed) > 0 {
+		log.Printf(" Resumed %d unfinished job(s)", len(resumed))
+	}
+
+	entries, err := os.ReadDir(m.tmpDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read temp directory: %w", err)
+	}
+	for _, e := range entries {
+		jobID, _, _ := strings.Cut(e.Name(), ".")
+		if resumed[jobID] {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(m.tmpDir, e.Name())); err != nil {
+			log.Printf(" Failed to remove stale temp file %s: %v", e.Name(), err)
+		}
+	}
+
+	return nil
+}
+
+// failResumed finishes a stored job that could not be resumed as failed.
+func (m *JobManager) failResumed(job models.Job, info *models.ErrorInfo) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry := &jobEntry{job: job, cancel: func() {}, done: make(chan struct{})}
+	m.jobs[job.ID] = entry
+	m.complete(entry, models.JobFailed, nil, info)
+}
+
+// Submit validates req, fills in defaults and queues it for processing.
+func (m *JobManager) Submit(req models.MergeRequest) (*models.Job, error) {
+	if m.storage == nil {
+		return nil, ErrStorageUnavailable
+	}
+
+	req = withMergeDefaults(req)
+	if err := validateMergeRequest(req); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().Unix()
+	job := models.Job{
+		ID:        uuid.New().String(),
+		Status:    models.JobQueued,
+		Request:   req,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	key := m.resultKey(job)
+	if result, err := m.cachedResult(key); err == nil {
+		// Links may be presigned and outlive their signature, so mint a new one.
+		if result.Key != "" {
+			if link, err := m.storage.URL(context.Background(), result.Key); err == nil {
+				result.URL = link
+			}
+		}
+		for i, sub := range result.Subtitles {
+			if link, err := m.storage.URL(context.Background(), sub.Key); err == nil {
+				result.Subtitles[i].URL = link
+			}
+		}
+		for i, chapter := range result.Chapters {
+			if link, err := m.storage.URL(context.Background(), chapter.Key); err == nil {
+				result.Chapters[i].URL = link
+			}
+		}
+		job.Status = models.JobDone
+		job.Result = result
+		if err := m.store.SaveJob(&job); err != nil {
+			return nil, err
+		}
+		m.expire(job.ID)
+		return &job, nil
+	}
+
+	return m.enqueue(job, key, true)
+}
+
+func withMergeDefaults(req models.MergeRequest) models.MergeRequest {
+	if req.Quality == "" {
+		req.Quality = "best"
+	}
+	if req.Type == "" {
+		req.Type = "video"
+	}
+	if req.Format == "" {
+		req.Format = "mp4"
+		if req.Type == "audio" {
+			req.Format = "mp3"
+		}
+	}
+	if len(req.Subtitles) > 0 && req.SubtitleMode == "" {
+		req.SubtitleMode = subtitleEmbed
+	}
+	return req
+}
+
+// enqueue registers job, whose result is stored under key, and hands it to
+// the worker pool. With coalesce set, a job producing the same result that is
+// still unfinished is returned instead, so it runs and holds a worker only
+// once.
+func (m *JobManager) enqueue(job models.Job, key string, coalesce bool) (*models.Job, error) {
+	token := uuid.New().String()
+
+	m.mu.Lock()
+	if id, ok := m.inflight[key]; ok && coalesce {
+		entry := m.jobs[id]
+		entry.requesters[token] = struct{}{}
+		attached := entry.job
+		m.mu.Unlock()
+		attached.CancelToken = token
+		return &attached, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	entry := &jobEntry{
+		job:        job,
+		cancel:     cancel,
+		done:       make(chan struct{}),
+		requesters: map[string]struct{}{token: {}},
+		resultKey:  key,
+	}
+	m.jobs[job.ID] = entry
+	if _, ok := m.inflight[key]; !ok {
+		m.inflight[key] = job.ID
+	}
+	m.mu.Unlock()
+
+	if err := m.pool.Submit(func() { m.run(ctx, entry) }); err != nil {
+		cancel()
+		m.mu.Lock()
+		delete(m.jobs, job.ID)
+		if m.inflight[key] == job.ID {
+			delete(m.inflight, key)
+		}
+		m.mu.Unlock()
+		return nil, err
+	}
+
+	if err := m.store.SaveJob(&job); err != nil {
+		log.Printf(" Failed to persist job %s: %v", job.ID, err)
+	}
+	job.CancelToken = token
+	return &job, nil
+}
+
+func (m *JobManager) Get(id string) (*models.Job, error) {
+	m.mu.Lock()
+	entry, ok := m.jobs[id]
+	if ok {
+		job := entry.job
+		m.mu.Unlock()
+		return &job, nil
+	}
+	m.mu.Unlock()
+
+	job, err := m.store.GetJob(id)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, ErrJobNotFound
+	}
+	return job, err
+}
+
+// Cancel withdraws the submission that received token from a queued or
+// running job. Once no submission is left the job is stopped, killing its
+// yt-dlp process if any; until then it keeps running for the others. Each
+// token works once, and tokens do not survive a restart.
+func (m *JobManager) Cancel(id, token string) (*models.Job, error) {
+	m.mu.Lock()
+	entry, ok := m.jobs[id]
+	if !ok {
+		m.mu.Unlock()
+		if _, err := m.Get(id); err != nil {
+			return nil, err
+		}
+		return nil, ErrJobFinished
+	}
+	if entry.job.Status.Finished() {
+		m.mu.Unlock()
+		return nil, ErrJobFinished
+	}
+	if _, ok := entry.requesters[token]; !ok {
+		m.mu.Unlock()
+		return nil, ErrCancelToken
+	}
+	delete(entry.requesters, token)
+	if len(entry.requesters) > 0 {
+		job := entry.job
+		m.mu.Unlock()
+		return &job, nil
+	}
+	m.complete(entry, models.JobCanceled, nil, nil)
+	job := entry.job
+	m.mu.Unlock()
+
+	entry.cancel()
+	return &job, nil
+}
+
+// Wait blocks until the job finishes or ctx is done.
+func (m *JobManager) Wait(ctx context.Context, id string) (*models.Job, error) {
+	m.mu.Lock()
+	entry, ok := m.jobs[id]
+	m.mu.Unlock()
+	if !ok {
+		// Jobs that are not tracked in memory have already finished.
+		return m.Get(id)
+	}
+
+	select {
+	case <-entry.done:
+		return m.Get(id)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (m *JobManager) run(ctx context.Context, entry *jobEntry) {
+	defer entry.cancel()
+
+	if !m.setStatus(entry, models.JobRunning) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, mergeTimeout)
+	defer cancel()
+
+	result, err := m.merge(ctx, entry)
+	if err == nil {
+		// Store and cache round trips must not hold m.mu; resultKey never
+		// changes once the entry is registered.
+		m.saveResult(entry.job.ID, entry.resultKey, result)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err != nil {
+		info := &models.ErrorInfo{
+			Code:    "INTERNAL_ERROR",
+			Message: "Merge job failed",
+			Details: err.Error(),
+		}
+		var jerr *jobError
+		if errors.As(err, &jerr) {
+			info.Code = jerr.code
+			info.Message = jerr.message
+		}
+		m.complete(entry, models.JobFailed, nil, info)
+		return
+	}
+
+	m.complete(entry, models.JobDone, result, nil)
+}
+
+func (m *JobManager) merge(ctx context.Context, entry *jobEntry) (*models.MergeResult, error) {
+	req := entry.job.Request
+
+	// The extension, and with it the key, of audio kept in its original
+	// codec is only known after downloading, so it is never deduplicated.
+	// Split merges store their chapters next to the key, never under it, so
+	// there is no object to reuse.
+	ext := outputExt(req)
+	var objectKey string
+	var metadata map[string]string
+	if ext != "" {
+		objectKey, metadata = m.objectKey(ctx, entry.job, ext)
+
+		if !req.SplitChapters {
+			if obj, err := m.storage.Stat(ctx, objectKey); err == nil {
+				publicURL, err := m.storage.URL(ctx, objectKey)
+				if err != nil {
+					return nil, &jobError{"UPLOAD_FAILED", "Failed to create link for existing file", err}
+				}
+				log.Printf(" Reusing stored object %s for job %s", objectKey, entry.job.ID)
+				result := &models.MergeResult{
+					URL:          publicURL,
+					Key:          objectKey,
+					Filename:     fmt.Sprintf("%s.%s", entry.job.ID, ext),
+					SHA256:       obj.Metadata[metaContentHash],
+					Deduplicated: true,
+				}
+				if req.SubtitleMode == subtitleSidecar {
+					result.Subtitles = m.storedSubtitles(ctx, objectKey)
+				}
+				return result, nil
+			} else if !errors.Is(err, ErrObjectNotFound) {
+				log.Printf(" Failed to look up %s, downloading again: %v", objectKey, err)
+			}
+		}
+
+		if uploader, ok := m.storage.(StreamUploader); ok && m.options.StreamUploads && CanStream(req) {
+			return m.mergeStream(ctx, entry, uploader, objectKey, metadata)
+		}
+	}
+
+	if err := os.MkdirAll(m.tmpDir, 0755); err != nil {
+		return nil, &jobError{"INTERNAL_ERROR", "Failed to create temporary directory", err}
+	}
+
+	defer m.removeTempFiles(entry.job.ID)
+
+	onProgress := func(p models.Progress) { m.setProgress(entry, p) }
+	tempPath, err := m.ytdlpService.DownloadToFile(ctx, req, filepath.Join(m.tmpDir, entry.job.ID), onProgress)
+	if errors.Is(err, ErrChapterNotFound) || errors.Is(err, ErrNoChapters) {
+		return nil, &jobError{"INVALID_INPUT", "Requested chapters are not available", err}
+	}
+	if errors.Is(err, ErrInvalidRequest) {
+		return nil, &jobError{"INVALID_INPUT", "Selected formats do not fit the container", err}
+	}
+	if err != nil {
+		return nil, &jobError{"DOWNLOAD_FAILED", "Failed to download video and Merge ", err}
+	}
+
+	// yt-dlp may fall back to another extension, e.g. when a single format
+	// needed no merge; store the file under what was actually produced.
+	if actual := strings.TrimPrefix(filepath.Ext(tempPath), "."); actual != ext {
+		ext = actual
+		objectKey, metadata = m.objectKey(ctx, entry.job, ext)
+	}
+	fileName := filepath.Base(tempPath)
+
+	if !m.setStatus(entry, models.JobUploading) {
+		return nil, context.Canceled
+	}
+	m.setProgress(entry, models.Progress{Phase: models.PhaseUpload})
+
+	if req.SplitChapters {
+		chapters, err := m.uploadChapters(ctx, req, filepath.Join(m.tmpDir, entry.job.ID), objectKey)
+		if err != nil {
+			return nil, &jobError{"UPLOAD_FAILED", "Failed to upload chapters to storage", err}
+		}
+		m.setProgress(entry, models.Progress{Phase: models.PhaseUpload, Percent: 100})
+		return &models.MergeResult{Filename: fileName, Chapters: chapters}, nil
+	}
+
+	var contentHash string
+	if m.options.ContentHash {
+		hash, err := fileSHA256(tempPath)
+		if err != nil {
+			return nil, &jobError{"INTERNAL_ERROR", "Failed to hash downloaded file", err}
+		}
+		contentHash = hash
+		metadata[metaContentHash] = hash
+	}
+
+	// Sidecars go first: a later job that finds the object reuses whatever
+	// tracks are stored next to it, so they must be complete by then.
+	var subtitles []models.SubtitleFile
+	if req.SubtitleMode == subtitleSidecar {
+		tracks := findSubtitles(filepath.Join(m.tmpDir, entry.job.ID))
+		if subtitles, err = m.uploadSubtitles(ctx, tracks, objectKey); err != nil {
+			return nil, &jobError{"UPLOAD_FAILED", "Failed to upload subtitles to storage", err}
+		}
+	}
+
+	publicURL, err := m.storage.Upload(ctx, tempPath, objectKey, metadata)
+	if err != nil {
+		return nil, &jobError{"UPLOAD_FAILED", "Failed to upload video to storage", err}
+	}
+	m.setProgress(entry, models.Progress{Phase: models.PhaseUpload, Percent: 100})
+
+	return &models.MergeResult{
+		URL:       publicURL,
+		Key:       objectKey,
+		Filename:  fileName,
+		SHA256:    contentHash,
+		Subtitles: subtitles,
+	}, nil
+}
+
+// mergeStream pipes the muxed output straight into storage, so the job is
+// uploading for its whole run and needs no temp file. The content hash is not
+// known until the upload ends, so it is never recorded for streamed objects.
+func (m *JobManager) mergeStream(ctx context.Context, entry *jobEntry, uploader StreamUploader, objectKey string, metadata map[string]string) (*models.MergeResult, error) {
+	req := entry.job.Request
+
+	if !m.setStatus(entry, models.JobUploading) {
+		return nil, context.Canceled
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	pr, pw := io.Pipe()
+	streamErr := make(chan error, 1)
+	go func() {
+		onProgress := func(p models.Progress) { m.setProgress(entry, p) }
+		err := m.ytdlpService.StreamTo(ctx, req, pw, onProgress)
+		pw.CloseWithError(err)
+		streamErr <- err
+	}()
+
+	publicURL, err := uploader.UploadStream(ctx, pr, objectKey, ContentType(path.Ext(objectKey)), metadata)
+	if err != nil {
+		// Unblock and stop the producer before reporting which side failed.
+		pr.CloseWithError(err)
+		cancel()
+		if downloadErr := <-streamErr; errors.Is(downloadErr, ErrInvalidRequest) {
+			return nil, &jobError{"INVALID_INPUT", "Selected formats do not fit the container", downloadErr}
+		} else if downloadErr != nil {
+			return nil, &jobError{"DOWNLOAD_FAILED", "Failed to download video and Merge ", downloadErr}
+		}
+		return nil, &jobError{"UPLOAD_FAILED", "Failed to upload video to storage", err}
+	}
+	if err := <-streamErr; errors.Is(err, ErrInvalidRequest) {
+		return nil, &jobError{"INVALID_INPUT", "Selected formats do not fit the container", err}
+	} else if err != nil {
+		return nil, &jobError{"DOWNLOAD_FAILED", "Failed to download video and Merge ", err}
+	}
+	m.setProgress(entry, models.Progress{Phase: models.PhaseUpload, Percent: 100})
+
+	return &models.MergeResult{
+		URL:      publicURL,
+		Key:      objectKey,
+		Filename: fmt.Sprintf("%s%s", entry.job.ID, path.Ext(objectKey)),
+	}, nil
+}
+
+// setStatus moves an unfinished job to status, reporting false if it was
+// already finished (for example canceled while queued).
+func (m *JobManager) setStatus(entry *jobEntry, status models.JobStatus) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if entry.job.Status.Finished() {
+		return false
+	}
+	entry.job.Status = status
+	entry.job.UpdatedAt = time.Now().Unix()
+	m.save(entry)
+	m.publishJob(entry)
+	return true
+}
+
+// setProgress records the latest progress of a running job and pushes it to
+// subscribers. Progress is only persisted along with status changes.
+func (m *JobManager) setProgress(entry *jobEntry, p models.Progress) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if entry.job.Status.Finished() {
+		return
+	}
+	entry.job.Progress = &p
+	m.publish(entry, models.JobEvent{Progress: &p})
+}
+
+// Subscribe streams status and progress events for a job. The channel is
+// closed once the job finishes; the returned function must be called to stop
+// listening earlier. Finished jobs yield a single status event.
+func (m *JobManager) Subscribe(id string) (<-chan models.JobEvent, func(), error) {
+	events := make(chan models.JobEvent, 16)
+
+	m.mu.Lock()
+	entry, ok := m.jobs[id]
+	if !ok || entry.job.Status.Finished() {
+		m.mu.Unlock()
+		job, err := m.Get(id)
+		if err != nil {
+			return nil, nil, err
+		}
+		events <- models.JobEvent{Job: job}
+		close(events)
+		return events, func() {}, nil
+	}
+
+	job := entry.job
+	events <- models.JobEvent{Job: &job}
+	if entry.subscribers == nil {
+		entry.subscribers = make(map[chan models.JobEvent]struct{})
+	}
+	entry.subscribers[events] = struct{}{}
+	m.mu.Unlock()
+
+	unsubscribe := func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if _, ok := entry.subscribers[events]; ok {
+			delete(entry.subscribers, events)
+			close(events)
+		}
+	}
+	return events, unsubscribe, nil
+}
+
+// publishJob must be called with m.mu held.
+func (m *JobManager) publishJob(entry *jobEntry) {
+	job := entry.job
+	m.publish(entry, models.JobEvent{Job: &job})
+}
+
+// publish must be called with m.mu held. Events are dropped for subscribers
+// that are not keeping up rather than blocking the job.
+func (m *JobManager) publish(entry *jobEntry, event models.JobEvent) {
+	for ch := range entry.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// complete must be called with m.mu held.
+func (m *JobManager) complete(entry *jobEntry, status models.JobStatus, result *models.MergeResult, info *models.ErrorInfo) {
+	if entry.job.Status.Finished() {
+		return
+	}
+
+	entry.job.Status = status
+	entry.job.Result = result
+	entry.job.Error = info
+	entry.job.UpdatedAt = time.Now().Unix()
+	close(entry.done)
+	m.save(entry)
+
+	if m.inflight[entry.resultKey] == entry.job.ID {
+		delete(m.inflight, entry.resultKey)
+	}
+
+	// The final status event must not be dropped: make room for it by
+	// discarding the oldest pending event of slow subscribers.
+	job := entry.job
+	for ch := range entry.subscribers {
+		select {
+		case ch <- models.JobEvent{Job: &job}:
+		default:
+			select {
+			case <-ch:
+			default:
+			}
+			ch <- models.JobEvent{Job: &job}
+		}
+		close(ch)
+	}
+	entry.subscribers = nil
+
+	m.expire(entry.job.ID)
+}
+
+// expire drops the record of a finished job once jobRetention has passed.
+func (m *JobManager) expire(id string) {
+	time.AfterFunc(jobRetention, func() {
+		m.mu.Lock()
+		delete(m.jobs, id)
+		m.mu.Unlock()
+		m.store.DeleteJob(id)
+	})
+}
+
+// saveResult records the result of a finished job under key so identical
+// requests reuse it. It must be called without m.mu held.
+func (m *JobManager) saveResult(jobID, key string, result *models.MergeResult) {
+	if err := m.store.SaveResult(key, result, resultTTL); err != nil {
+		log.Printf(" Failed to persist result for job %s: %v", jobID, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), resultIOTimeout)
+	defer cancel()
+	if err := m.cache.Set(ctx, key, result, resultTTL); err != nil {
+		log.Printf(" Failed to cache result for job %s: %v", jobID, err)
+	}
+}
+
+// cachedResult looks up a finished upload stored under key, first in the
+// shared cache and then in the local store.
+func (m *JobManager) cachedResult(key string) (*models.MergeResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), resultIOTimeout)
+	defer cancel()
+
+	var result models.MergeResult
+	err := m.cache.Get(ctx, key, &result)
+	if err == nil {
+		return &result, nil
+	}
+	if !errors.Is(err, cache.ErrMiss) {
+		log.Printf(" Cache read failed for %s: %v", key, err)
+	}
+	return m.store.GetResult(key)
+}
+
+// save must be called with m.mu held.
+func (m *JobManager) save(entry *jobEntry) {
+	if err := m.store.SaveJob(&entry.job); err != nil {
+		log.Printf(" Failed to persist job %s: %v", entry.job.ID, err)
+	}
+}
+
+func (m *JobManager) removeTempFiles(jobID string) {
+	matches, _ := filepath.Glob(filepath.Join(m.tmpDir, jobID+".*"))
+	for _, path := range matches {
+		os.Remove(path)
+	}
+}
+
+func (m *JobManager) resultKey(job models.Job) string {
+	req := job.Request
+	return fmt.Sprintf("upload_%s_%s_%s_%s%s", m.ytdlpService.CacheKey(req.URL), req.Type, req.Format, formatSelector(req), outputOptions(req))
+}
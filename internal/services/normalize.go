@@ -0,0 +1,82 @@
+package services
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+var (
+	youtubeIDPattern   = regexp.MustCompile(`^[A-Za-z0-9_-]{11}$`)
+	numericIDPattern   = regexp.MustCompile(`^[0-9]+$`)
+	instagramIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+)
+
+// NormalizeURL maps URLs of well-known sites to the same "extractor:id" key
+// yt-dlp would report, without spawning it. ok is false for URLs it does
+// not recognise; callers should then fall back to CanonicalID.
+func NormalizeURL(rawURL string) (key string, ok bool) {
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "https://" + rawURL
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", false
+	}
+
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	host = strings.TrimPrefix(host, "m.")
+	segments := strings.FieldsFunc(u.Path, func(r rune) bool { return r == '/' })
+
+	switch host {
+	case "youtube.com", "music.youtube.com", "youtube-nocookie.com":
+		if len(segments) == 1 && segments[0] == "watch" {
+			return matchID("youtube", u.Query().Get("v"), youtubeIDPattern)
+		}
+		if len(segments) >= 2 {
+			switch segments[0] {
+			case "shorts", "embed", "live", "v":
+				return matchID("youtube", segments[1], youtubeIDPattern)
+			}
+		}
+	case "youtu.be":
+		if len(segments) >= 1 {
+			return matchID("youtube", segments[0], youtubeIDPattern)
+		}
+	case "tiktok.com":
+		// https://www.tiktok.com/@user/video/<id>
+		if len(segments) >= 3 && strings.HasPrefix(segments[0], "@") && segments[1] == "video" {
+			return matchID("tiktok", segments[2], numericIDPattern)
+		}
+	case "instagram.com":
+		// https://www.instagram.com/p/<code>/, /reel/<code>/, /tv/<code>/
+		if len(segments) >= 2 {
+			switch segments[0] {
+			case "p", "reel", "reels", "tv":
+				return matchID("instagram", segments[1], instagramIDPattern)
+			}
+		}
+	case "x.com", "twitter.com", "mobile.twitter.com", "mobile.x.com":
+		// https://x.com/<user>/status/<id>
+		if len(segments) >= 3 && segments[1] == "status" {
+			return matchID("twitter", segments[2], numericIDPattern)
+		}
+	case "vimeo.com":
+		if len(segments) >= 1 {
+			return matchID("vimeo", segments[0], numericIDPattern)
+		}
+	case "player.vimeo.com":
+		if len(segments) >= 2 && segments[0] == "video" {
+			return matchID("vimeo", segments[1], numericIDPattern)
+		}
+	}
+
+	return "", false
+}
+
+func matchID(extractor, id string, pattern *regexp.Regexp) (string, bool) {
+	if !pattern.MatchString(id) {
+		return "", false
+	}
+	return extractor + ":" + id, true
+}
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pavelc4/ytdpl-api-go/internal/cache"
+)
+
+func TestNormalizeURL(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"https://www.youtube.com/watch?v=dQw4w9WgXcQ", "youtube:dQw4w9WgXcQ"},
+		{"https://youtube.com/watch?v=dQw4w9WgXcQ&t=10", "youtube:dQw4w9WgXcQ"},
+		{"https://www.youtube.com/watch?v=dQw4w9WgXcQ&list=PLFgquLnL59alCl_2TQvOiD5Vgm1hCaGSI&index=2", "youtube:dQw4w9WgXcQ"},
+		{"https://www.youtube.com/watch?feature=share&si=abc123&v=dQw4w9WgXcQ&utm_source=x", "youtube:dQw4w9WgXcQ"},
+		{"https://m.youtube.com/watch?v=dQw4w9WgXcQ", "youtube:dQw4w9WgXcQ"},
+		{"https://music.youtube.com/watch?v=dQw4w9WgXcQ", "youtube:dQw4w9WgXcQ"},
+		{"https://WWW.YouTube.com/watch?v=dQw4w9WgXcQ", "youtube:dQw4w9WgXcQ"},
+		{"www.youtube.com/watch?v=dQw4w9WgXcQ", "youtube:dQw4w9WgXcQ"},
+		{"https://youtu.be/dQw4w9WgXcQ", "youtube:dQw4w9WgXcQ"},
+		{"https://youtu.be/dQw4w9WgXcQ?si=abc123&t=42", "youtube:dQw4w9WgXcQ"},
+		{"https://www.youtube.com/shorts/dQw4w9WgXcQ", "youtube:dQw4w9WgXcQ"},
+		{"https://youtube.com/shorts/dQw4w9WgXcQ?feature=share", "youtube:dQw4w9WgXcQ"},
+		{"https://www.youtube.com/embed/dQw4w9WgXcQ", "youtube:dQw4w9WgXcQ"},
+		{"https://www.youtube.com/live/dQw4w9WgXcQ", "youtube:dQw4w9WgXcQ"},
+		{"https://www.youtube-nocookie.com/embed/dQw4w9WgXcQ", "youtube:dQw4w9WgXcQ"},
+		{"https://www.tiktok.com/@user/video/7234567890123456789?lang=en", "tiktok:7234567890123456789"},
+		{"https://www.instagram.com/reel/Cabc_12-x/?igsh=abc", "instagram:Cabc_12-x"},
+		{"https://www.instagram.com/p/Cabc123/", "instagram:Cabc123"},
+		{"https://x.com/user/status/1234567890", "twitter:1234567890"},
+		{"https://twitter.com/user/status/1234567890?s=20", "twitter:1234567890"},
+		{"https://mobile.twitter.com/user/status/1234567890", "twitter:1234567890"},
+		{"https://vimeo.com/123456789", "vimeo:123456789"},
+		{"https://player.vimeo.com/video/123456789?h=abc", "vimeo:123456789"},
+
+		// Not recognised: callers fall back to yt-dlp.
+		{"https://www.youtube.com/playlist?list=PLFgquLnL59alCl_2TQvOiD5Vgm1hCaGSI", ""},
+		{"https://www.youtube.com/watch?list=PLFgquLnL59alCl_2TQvOiD5Vgm1hCaGSI", ""},
+		{"https://www.youtube.com/watch?v=short", ""},
+		{"https://www.youtube.com/watch?v=dQw4w9WgXcQ/../x", ""},
+		{"https://www.youtube.com/@channel", ""},
+		{"https://youtu.be/", ""},
+		{"https://www.tiktok.com/@user", ""},
+		{"https://x.com/user", ""},
+		{"https://vimeo.com/channels/staffpicks", ""},
+		{"https://example.com/watch?v=dQw4w9WgXcQ", ""},
+		{"https://youtube.com.example.com/watch?v=dQw4w9WgXcQ", ""},
+		{"https://notyoutu.be/dQw4w9WgXcQ", ""},
+		{"http://[::1", ""},
+	}
+
+	for _, tt := range tests {
+		got, ok := NormalizeURL(tt.url)
+		if ok != (tt.want != "") || got != tt.want {
+			t.Errorf("NormalizeURL(%q) = %q, %t, want %q", tt.url, got, ok, tt.want)
+		}
+	}
+}
+
+func TestCanonicalIDWithoutYTDLP(t *testing.T) {
+	c := cache.NewMemoryCache()
+	s := NewYTDLPService("", nil, c)
+	ctx := context.Background()
+
+	// Unknown hosts are resolved from the extractor_key/id remembered from an
+	// earlier extraction.
+	s.rememberID(ctx, "https://example.com/video/42", "Generic", "42")
+
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"https://youtu.be/dQw4w9WgXcQ", "youtube:dQw4w9WgXcQ"},
+		{"https://www.youtube.com/watch?v=dQw4w9WgXcQ&list=PL123", "youtube:dQw4w9WgXcQ"},
+		{"https://example.com/video/42", "generic:42"},
+	}
+
+	for _, tt := range tests {
+		got, err := s.CanonicalID(ctx, tt.url)
+		if err != nil || got != tt.want {
+			t.Errorf("CanonicalID(%q) = %q, %v, want %q", tt.url, got, err, tt.want)
+		}
+		if key := s.CacheKey(tt.url); key != tt.want {
+			t.Errorf("CacheKey(%q) = %q, want %q", tt.url, key, tt.want)
+		}
+	}
+
+	// Unknown URLs nobody has extracted yet are their own cache key.
+	if key := s.CacheKey("https://example.com/other"); key != "https://example.com/other" {
+		t.Errorf("CacheKey(unknown) = %q, want the URL itself", key)
+	}
+}
@@ -0,0 +1,86 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+
+	"github.com/pavelc4/ytdpl-api-go/internal/models"
+)
+
+// GetPlaylist lists limit entries of the playlist, channel tab or mix at url,
+// starting at offset. Entries are not extracted individually, so only what
+// the listing itself provides is returned.
+func (s *YTDLPService) GetPlaylist(ctx context.Context, url string, offset, limit int) (*models.Playlist, error) {
+	cacheKey := fmt.Sprintf("playlist_%s_%d_%d", url, offset, limit)
+	var cached models.Playlist
+	if s.cacheGet(ctx, cacheKey, &cached) {
+		return &cached, nil
+	}
+
+	args := []string{
+		"--flat-playlist",
+		"-J",
+		"--yes-playlist",
+		"--playlist-items", fmt.Sprintf("%d:%d", offset+1, offset+limit),
+		"--no-warnings",
+		"--no-cache-dir",
+	}
+
+	if path, err := exec.LookPath("bun"); err == nil && path != "" {
+		args = append(args, "--js-runtimes", "bun")
+	}
+
+	if s.cookiePath != "" {
+		args = append(args, "--cookies", s.cookiePath)
+	}
+
+	args = append(args, url)
+
+	output, err := s.run(ctx, args)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list playlist: %w (output: %s)", err, string(output))
+	}
+
+	var data models.YTDLPPlaylistOutput
+	if err := json.Unmarshal(output, &data); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON: %w", err)
+	}
+
+	playlist := &models.Playlist{
+		ID:         data.ID,
+		Title:      data.Title,
+		Uploader:   data.Uploader,
+		EntryCount: data.PlaylistCount,
+		Offset:     offset,
+		Limit:      limit,
+		Entries:    make([]models.PlaylistEntry, 0, len(data.Entries)),
+	}
+	if playlist.Uploader == "" {
+		playlist.Uploader = data.Channel
+	}
+
+	for _, e := range data.Entries {
+		entry := models.PlaylistEntry{
+			ID:        e.ID,
+			Title:     e.Title,
+			Duration:  int(e.Duration),
+			Thumbnail: e.Thumbnail,
+			URL:       e.URL,
+		}
+		// Flat entries usually list thumbnails smallest first instead of
+		// naming one.
+		if entry.Thumbnail == "" && len(e.Thumbnails) > 0 {
+			entry.Thumbnail = e.Thumbnails[len(e.Thumbnails)-1].URL
+		}
+		if entry.URL == "" {
+			entry.URL = e.WebpageURL
+		}
+		playlist.Entries = append(playlist.Entries, entry)
+	}
+
+	s.cacheSet(ctx, cacheKey, playlist, extractionTTL)
+
+	return playlist, nil
+}
@@ -0,0 +1,72 @@
+package services
+
+import (
+	"context"
+	"errors"
+)
+
+var ErrPoolFull = errors.New("worker pool queue is full")
+
+// WorkerPool runs tasks on a fixed number of goroutines fed by a bounded queue.
+type WorkerPool struct {
+	tasks chan func()
+}
+
+func NewWorkerPool(workers, queueSize int) *WorkerPool {
+	if workers < 1 {
+		workers = 1
+	}
+	if queueSize < 0 {
+		queueSize = 0
+	}
+
+	p := &WorkerPool{
+		tasks: make(chan func(), queueSize),
+	}
+
+	for i := 0; i < workers; i++ {
+		go func() {
+			for task := range p.tasks {
+				task()
+			}
+		}()
+	}
+
+	return p
+}
+
+// Submit queues a task without waiting for it to run.
+// It returns ErrPoolFull instead of blocking when the queue has no room.
+func (p *WorkerPool) Submit(task func()) error {
+	select {
+	case p.tasks <- task:
+		return nil
+	default:
+		return ErrPoolFull
+	}
+}
+
+// Do runs fn on the pool and waits for it to finish or for ctx to be done.
+func (p *WorkerPool) Do(ctx context.Context, fn func() error) error {
+	done := make(chan error, 1)
+	task := func() {
+		if err := ctx.Err(); err != nil {
+			done <- err
+			return
+		}
+		done <- fn()
+	}
+
+	select {
+	case p.tasks <- task:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
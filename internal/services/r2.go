@@ -1,23 +1,33 @@
 package services
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"net/url"
 	"os"
+	"path"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/pavelc4/ytdpl-api-go/config"
 )
 
+// R2Service stores objects in Cloudflare R2 or any other S3-compatible bucket.
 type R2Service struct {
-	client    *s3.Client
-	bucket    string
-	publicURL string
+	client     *s3.Client
+	presigner  *s3.PresignClient
+	bucket     string
+	publicURL  string
+	private    bool
+	presignTTL time.Duration
+	partSize   int
 }
 
 func NewR2Service(cfg config.R2Config) (*R2Service, error) {
@@ -40,12 +50,17 @@ func NewR2Service(cfg config.R2Config) (*R2Service, error) {
 	client := s3.NewFromConfig(awsCfg)
 
 	return &R2Service{
-		client:    client,
-		bucket:    cfg.BucketName,
-		publicURL: cfg.PublicURL,
+		client:     client,
+		presigner:  s3.NewPresignClient(client),
+		bucket:     cfg.BucketName,
+		publicURL:  cfg.PublicURL,
+		private:    cfg.Private,
+		presignTTL: cfg.PresignTTL,
+		partSize:   max(cfg.PartSizeMB, 5) << 20,
 	}, nil
 }
-func (r *R2Service) UploadFile(ctx context.Context, localPath, objectKey string) (string, error) {
+
+func (r *R2Service) Upload(ctx context.Context, localPath, objectKey string, metadata map[string]string) (string, error) {
 	file, err := os.Open(localPath)
 	if err != nil {
 		return "", fmt.Errorf("failed to open file: %w", err)
@@ -53,19 +68,118 @@ func (r *R2Service) UploadFile(ctx context.Context, localPath, objectKey string)
 	defer file.Close()
 
 	_, err = r.client.PutObject(ctx, &s3.PutObjectInput{
-		Bucket: aws.String(r.bucket),
-		Key:    aws.String(objectKey),
-		Body:   file,
+		Bucket:      aws.String(r.bucket),
+		Key:         aws.String(objectKey),
+		Body:        file,
+		ContentType: aws.String(ContentType(path.Ext(objectKey))),
+		Metadata:    metadata,
 	})
 	if err != nil {
 		return "", fmt.Errorf("failed to upload to R2: %w", err)
 	}
 
-	publicURL := fmt.Sprintf("%s/%s", r.publicURL, objectKey)
+	return r.URL(ctx, objectKey)
+}
+
+// UploadStream stores body with a multipart upload, holding at most one part
+// in memory at a time.
+func (r *R2Service) UploadStream(ctx context.Context, body io.Reader, objectKey, contentType string, metadata map[string]string) (string, error) {
+	created, err := r.client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:      aws.String(r.bucket),
+		Key:         aws.String(objectKey),
+		ContentType: aws.String(contentType),
+		Metadata:    metadata,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to start multipart upload: %w", err)
+	}
+
+	abort := func() {
+		_, err := r.client.AbortMultipartUpload(context.Background(), &s3.AbortMultipartUploadInput{
+			Bucket:   aws.String(r.bucket),
+			Key:      aws.String(objectKey),
+			UploadId: created.UploadId,
+		})
+		if err != nil {
+			log.Printf(" Failed to abort multipart upload of %s: %v", objectKey, err)
+		}
+	}
+
+	buf := make([]byte, r.partSize)
+	var parts []types.CompletedPart
+
+	for partNumber := int32(1); ; partNumber++ {
+		n, readErr := io.ReadFull(body, buf)
+		if readErr != nil && readErr != io.EOF && readErr != io.ErrUnexpectedEOF {
+			abort()
+			return "", fmt.Errorf("failed to read upload stream: %w", readErr)
+		}
+
+		if n > 0 || partNumber == 1 {
+			part, err := r.client.UploadPart(ctx, &s3.UploadPartInput{
+				Bucket:     aws.String(r.bucket),
+				Key:        aws.String(objectKey),
+				UploadId:   created.UploadId,
+				PartNumber: aws.Int32(partNumber),
+				Body:       bytes.NewReader(buf[:n]),
+			})
+			if err != nil {
+				abort()
+				return "", fmt.Errorf("failed to upload part %d: %w", partNumber, err)
+			}
+			parts = append(parts, types.CompletedPart{
+				ETag:       part.ETag,
+				PartNumber: aws.Int32(partNumber),
+			})
+		}
+
+		if readErr != nil {
+			break
+		}
+	}
+
+	_, err = r.client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(r.bucket),
+		Key:             aws.String(objectKey),
+		UploadId:        created.UploadId,
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: parts},
+	})
+	if err != nil {
+		abort()
+		return "", fmt.Errorf("failed to complete multipart upload: %w", err)
+	}
+
+	return r.URL(ctx, objectKey)
+}
+
+// Copy copies srcKey to destKey within the bucket, keeping its content type
+// and metadata.
+func (r *R2Service) Copy(ctx context.Context, srcKey, destKey string) (string, error) {
+	source := (&url.URL{Path: r.bucket + "/" + srcKey}).EscapedPath()
+	_, err := r.client.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:     aws.String(r.bucket),
+		Key:        aws.String(destKey),
+		CopySource: aws.String(source),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to copy object in R2: %w", err)
+	}
+
+	return r.URL(ctx, destKey)
+}
+
+// URL returns a presigned link when the bucket is private and a public
+// bucket link otherwise.
+func (r *R2Service) URL(ctx context.Context, key string) (string, error) {
+	if r.private {
+		return r.PresignGet(ctx, key, 0)
+	}
+
+	publicURL := fmt.Sprintf("%s/%s", r.publicURL, key)
 	return publicURL, nil
 }
 
-func (r *R2Service) DeleteFile(ctx context.Context, objectKey string) error {
+func (r *R2Service) Delete(ctx context.Context, objectKey string) error {
 	_, err := r.client.DeleteObject(ctx, &s3.DeleteObjectInput{
 		Bucket: aws.String(r.bucket),
 		Key:    aws.String(objectKey),
@@ -73,48 +187,71 @@ func (r *R2Service) DeleteFile(ctx context.Context, objectKey string) error {
 	return err
 }
 
-func (r *R2Service) CleanupOldFiles(ctx context.Context, retentionDays int) error {
-	log.Printf(" Starting cleanup of files older than %d days...", retentionDays)
+func (r *R2Service) List(ctx context.Context, prefix string) ([]StorageObject, error) {
+	var objects []StorageObject
 
-	prefixes := []string{"vidioe/", "audio/"}
-	deletedCount := 0
-	errorsCount := 0
-	cutoff := time.Now().AddDate(0, 0, -retentionDays)
+	paginator := s3.NewListObjectsV2Paginator(r.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(r.bucket),
+		Prefix: aws.String(prefix),
+	})
 
-	for _, prefix := range prefixes {
-		paginator := s3.NewListObjectsV2Paginator(r.client, &s3.ListObjectsV2Input{
-			Bucket: aws.String(r.bucket),
-			Prefix: aws.String(prefix),
-		})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return objects, fmt.Errorf("failed to list objects: %w", err)
+		}
 
-		for paginator.HasMorePages() {
-			page, err := paginator.NextPage(ctx)
-			if err != nil {
-				log.Printf("Failed to list objects for prefix %s: %v", prefix, err)
-				errorsCount++
-				continue
-			}
+		for _, obj := range page.Contents {
+			objects = append(objects, StorageObject{
+				Key:          aws.ToString(obj.Key),
+				Size:         aws.ToInt64(obj.Size),
+				LastModified: aws.ToTime(obj.LastModified),
+			})
+		}
+	}
 
-			for _, obj := range page.Contents {
-				if obj.LastModified.Before(cutoff) {
-					err := r.DeleteFile(ctx, *obj.Key)
-					if err != nil {
-						log.Printf(" Failed to delete %s: %v", *obj.Key, err)
-						errorsCount++
-					} else {
-						log.Printf(" Deleted old file: %s (Last modified: %s)", *obj.Key, obj.LastModified.Format(time.RFC3339))
-						deletedCount++
-					}
-				}
-			}
+	return objects, nil
+}
+
+// PresignGet returns a presigned GET URL for key that expires after ttl, or
+// after the configured presign TTL when ttl is not positive.
+func (r *R2Service) PresignGet(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	if ttl <= 0 {
+		ttl = r.presignTTL
+	}
+
+	req, err := r.presigner.PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(r.bucket),
+		Key:    aws.String(key),
+	}, s3.WithPresignExpires(ttl))
+	if err != nil {
+		return "", fmt.Errorf("failed to presign object: %w", err)
+	}
+	return req.URL, nil
+}
+
+func (r *R2Service) Stat(ctx context.Context, key string) (*StorageObject, error) {
+	result, err := r.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(r.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		var notFound *types.NotFound
+		if errors.As(err, &notFound) {
+			return nil, ErrObjectNotFound
 		}
+		return nil, fmt.Errorf("failed to stat object: %w", err)
 	}
 
-	log.Printf(" Cleanup completed. Deleted: %d, Errors: %d", deletedCount, errorsCount)
-	return nil
+	return &StorageObject{
+		Key:          key,
+		Size:         aws.ToInt64(result.ContentLength),
+		LastModified: aws.ToTime(result.LastModified),
+		Metadata:     result.Metadata,
+	}, nil
 }
 
-func (r *R2Service) DownloadFile(ctx context.Context, objectKey, destPath string) error {
+func (r *R2Service) Download(ctx context.Context, objectKey, destPath string) error {
 	result, err := r.client.GetObject(ctx, &s3.GetObjectInput{
 		Bucket: aws.String(r.bucket),
 		Key:    aws.String(objectKey),
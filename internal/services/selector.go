@@ -0,0 +1,259 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/pavelc4/ytdpl-api-go/internal/models"
+)
+
+// ErrInvalidRequest is wrapped by Submit when a merge request is rejected
+// before anything is queued.
+var ErrInvalidRequest = errors.New("invalid merge request")
+
+const maxSelectorLength = 256
+
+// presetHeights are the quality presets that cap the video height.
+var presetHeights = map[string]int{
+	"480p":  480,
+	"720p":  720,
+	"1080p": 1080,
+	"1440p": 1440,
+	"2160p": 2160,
+}
+
+// videoPresets are the named quality values a video merge accepts.
+var videoPresets = map[string]string{
+	"best":            "bestvideo+bestaudio/best",
+	"480p":            heightSelector(presetHeights["480p"]),
+	"720p":            heightSelector(presetHeights["720p"]),
+	"1080p":           heightSelector(presetHeights["1080p"]),
+	"1440p":           heightSelector(presetHeights["1440p"]),
+	"2160p":           heightSelector(presetHeights["2160p"]),
+	"smallest":        "worstvideo+worstaudio/worst",
+	"h264-compatible": "bestvideo[vcodec^=avc1]+bestaudio[acodec^=mp4a]/best[vcodec^=avc1]/bestvideo+bestaudio/best",
+	"av1-preferred":   "bestvideo[vcodec^=av01]+bestaudio/bestvideo+bestaudio/best",
+}
+
+func heightSelector(height int) string {
+	return fmt.Sprintf("bestvideo[height<=%d]+bestaudio/best[height<=%d]", height, height)
+}
+
+// formatSelector returns the yt-dlp -f expression for req: its selector or
+// format_id when set, otherwise its quality preset, preferring streams its
+// container accepts. req must have passed validateMergeRequest.
+func formatSelector(req models.MergeRequest) string {
+	switch {
+	case req.Selector != "":
+		return req.Selector
+	case req.FormatID != "":
+		return req.FormatID
+	case req.Type == "audio" && req.Quality == "smallest":
+		return "worstaudio/worst"
+	case req.Type == "audio":
+		return "bestaudio/best"
+	}
+	selector, ok := videoPresets[req.Quality]
+	if !ok {
+		selector = videoPresets["best"]
+	}
+	if compatible := compatibleSelector(req); compatible != "" {
+		return compatible + "/" + selector
+	}
+	return selector
+}
+
+// formatIDPattern accepts IDs joined by +. IDs must not start with - so
+// none of them can be read as a yt-dlp option.
+var formatIDPattern = regexp.MustCompile(`^[A-Za-z0-9_.][A-Za-z0-9_.-]*(\+[A-Za-z0-9_.][A-Za-z0-9_.-]*)*$`)
+
+// validateMergeRequest rejects format options yt-dlp would not understand,
+// so that no process is spawned for them.
+func validateMergeRequest(req models.MergeRequest) error {
+	if req.Type != "video" && req.Type != "audio" {
+		return fmt.Errorf("%w: type must be video or audio", ErrInvalidRequest)
+	}
+	if req.Type == "audio" {
+		if err := validateAudio(req); err != nil {
+			return err
+		}
+	}
+	if err := validateContainer(req); err != nil {
+		return err
+	}
+	if err := validateTags(req); err != nil {
+		return err
+	}
+	if err := validateSubtitles(req); err != nil {
+		return err
+	}
+	if err := validateClip(req); err != nil {
+		return err
+	}
+	if req.Selector != "" && req.FormatID != "" {
+		return fmt.Errorf("%w: set either selector or format_id, not both", ErrInvalidRequest)
+	}
+	if req.FormatID != "" && !formatIDPattern.MatchString(req.FormatID) {
+		return fmt.Errorf("%w: invalid format_id %q", ErrInvalidRequest, req.FormatID)
+	}
+	if req.Selector != "" {
+		if err := ValidateSelector(req.Selector); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+		}
+	}
+	if _, ok := videoPresets[req.Quality]; !ok {
+		return fmt.Errorf("%w: unknown quality %q", ErrInvalidRequest, req.Quality)
+	}
+	return nil
+}
+
+// selectorFields are the format fields a selector may filter on, mapped to
+// whether they are numeric.
+var selectorFields = map[string]bool{
+	"filesize": true, "filesize_approx": true, "width": true, "height": true,
+	"aspect_ratio": true, "tbr": true, "abr": true, "vbr": true, "asr": true,
+	"fps": true, "audio_channels": true, "quality": true,
+
+	"ext": false, "acodec": false, "vcodec": false, "container": false,
+	"protocol": false, "format_id": false, "language": false,
+	"dynamic_range": false, "format_note": false, "resolution": false,
+}
+
+var (
+	// Names must not start with -, which yt-dlp could take for an option.
+	selectorNamePattern  = regexp.MustCompile(`^[A-Za-z0-9_.*][A-Za-z0-9_.*-]*`)
+	selectorValuePattern = regexp.MustCompile(`^[A-Za-z0-9_.:+-]+$`)
+	numericOperators     = []string{"<=", ">=", "!=", "<", ">", "="}
+	stringOperators      = []string{"!^=", "!$=", "!*=", "!~=", "^=", "$=", "*=", "~=", "!=", "="}
+)
+
+// ValidateSelector checks that expr is a single-file yt-dlp format selector:
+// names or format IDs combined with / (fallback) and + (merge), optionally
+// grouped with parentheses and narrowed by [field op value] filters. The ,
+// operator is rejected because it downloads several files.
+func ValidateSelector(expr string) error {
+	if len(expr) > maxSelectorLength {
+		return fmt.Errorf("selector longer than %d characters", maxSelectorLength)
+	}
+	p := &selectorParser{input: expr}
+	if err := p.alternatives(); err != nil {
+		return err
+	}
+	if p.pos != len(p.input) {
+		return p.errorf("unexpected %q", p.input[p.pos])
+	}
+	return nil
+}
+
+type selectorParser struct {
+	input string
+	pos   int
+}
+
+func (p *selectorParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("invalid selector at position %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *selectorParser) peek() byte {
+	if p.pos < len(p.input) {
+		return p.input[p.pos]
+	}
+	return 0
+}
+
+func (p *selectorParser) alternatives() error {
+	for {
+		if err := p.merge(); err != nil {
+			return err
+		}
+		if p.peek() != '/' {
+			return nil
+		}
+		p.pos++
+	}
+}
+
+func (p *selectorParser) merge() error {
+	for {
+		if err := p.term(); err != nil {
+			return err
+		}
+		if p.peek() != '+' {
+			return nil
+		}
+		p.pos++
+	}
+}
+
+func (p *selectorParser) term() error {
+	switch {
+	case p.peek() == '(':
+		p.pos++
+		if err := p.alternatives(); err != nil {
+			return err
+		}
+		if p.peek() != ')' {
+			return p.errorf("missing )")
+		}
+		p.pos++
+	case p.peek() == '[':
+		// A bare filter applies to "best", as in yt-dlp.
+	default:
+		name := selectorNamePattern.FindString(p.input[p.pos:])
+		if name == "" {
+			if p.pos == len(p.input) {
+				return p.errorf("unexpected end")
+			}
+			return p.errorf("unexpected %q", p.input[p.pos])
+		}
+		p.pos += len(name)
+	}
+
+	for p.peek() == '[' {
+		if err := p.filter(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *selectorParser) filter() error {
+	end := strings.IndexByte(p.input[p.pos:], ']')
+	if end < 0 {
+		return p.errorf("missing ]")
+	}
+	body := p.input[p.pos+1 : p.pos+end]
+
+	field := strings.TrimLeft(body, "abcdefghijklmnopqrstuvwxyz_")
+	field = body[:len(body)-len(field)]
+	numeric, ok := selectorFields[field]
+	if !ok {
+		return p.errorf("unknown filter field %q", field)
+	}
+
+	rest := body[len(field):]
+	operators := stringOperators
+	if numeric {
+		operators = numericOperators
+	}
+	var operator string
+	for _, op := range operators {
+		if strings.HasPrefix(rest, op) {
+			operator = op
+			break
+		}
+	}
+	if operator == "" {
+		return p.errorf("invalid operator in filter [%s]", body)
+	}
+
+	value := strings.TrimPrefix(rest[len(operator):], "?")
+	if !selectorValuePattern.MatchString(value) {
+		return p.errorf("invalid value in filter [%s]", body)
+	}
+
+	p.pos += end + 1
+	return nil
+}
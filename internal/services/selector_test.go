@@ -0,0 +1,161 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/pavelc4/ytdpl-api-go/internal/models"
+)
+
+func TestValidateSelector(t *testing.T) {
+	tests := []struct {
+		expr  string
+		valid bool
+	}{
+		{"best", true},
+		{"bestvideo+bestaudio/best", true},
+		{"137+140", true},
+		{"hls-1080p", true},
+		{"bv*[height<=1080]+ba/b", true},
+		{"(bv+ba/b)[ext=mp4]", true},
+		{"[height<=720]", true},
+		{"best[height<=?720]", true},
+		{"bestaudio[acodec^=opus]/bestaudio", true},
+		{"best[filesize<50M]", true},
+		{"bv[vcodec!*=av01]+ba[language=en-US]", true},
+
+		{"", false},
+		{"-f", false},
+		{"--exec=id", false},
+		{"best/-x", false},
+		{"bv+-ba", false},
+		{"(-x)", false},
+		{"bv,ba", false},
+		{"best;id", false},
+		{"best[exec=id]", false},
+		{"best[height~=1]", false},
+		{"best[ext=mp4", false},
+		{"best[ext=$(id)]", false},
+		{"best[ext='mp4']", false},
+		{"(best", false},
+		{"best)", false},
+		{"best+", false},
+		{"best/", false},
+		{strings.Repeat("b", maxSelectorLength+1), false},
+	}
+
+	for _, tt := range tests {
+		err := ValidateSelector(tt.expr)
+		if tt.valid && err != nil {
+			t.Errorf("ValidateSelector(%q) = %v, want nil", tt.expr, err)
+		}
+		if !tt.valid && err == nil {
+			t.Errorf("ValidateSelector(%q) = nil, want error", tt.expr)
+		}
+	}
+}
+
+func TestValidateMergeRequestFormatOptions(t *testing.T) {
+	tests := []struct {
+		name  string
+		req   models.MergeRequest
+		valid bool
+	}{
+		{"preset", models.MergeRequest{Quality: "1080p"}, true},
+		{"format_id", models.MergeRequest{FormatID: "137+140"}, true},
+		{"selector", models.MergeRequest{Selector: "bv*+ba/b"}, true},
+		{"unknown preset", models.MergeRequest{Quality: "4k"}, false},
+		{"format_id and selector", models.MergeRequest{FormatID: "137", Selector: "best"}, false},
+		{"format_id flag", models.MergeRequest{FormatID: "-x"}, false},
+		{"format_id merged flag", models.MergeRequest{FormatID: "137+--exec"}, false},
+		{"format_id with spaces", models.MergeRequest{FormatID: "137 140"}, false},
+		{"selector flag", models.MergeRequest{Selector: "--exec"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateMergeRequest(withMergeDefaults(tt.req))
+			if tt.valid && err != nil {
+				t.Fatalf("validateMergeRequest() = %v, want nil", err)
+			}
+			if !tt.valid && !errors.Is(err, ErrInvalidRequest) {
+				t.Fatalf("validateMergeRequest() = %v, want ErrInvalidRequest", err)
+			}
+		})
+	}
+}
+
+func TestFormatSelectorPresets(t *testing.T) {
+	for quality, preset := range videoPresets {
+		req := withMergeDefaults(models.MergeRequest{Quality: quality, Format: "mkv"})
+		if err := validateMergeRequest(req); err != nil {
+			t.Errorf("validateMergeRequest(%s) = %v", quality, err)
+		}
+		// mkv takes any codec, so presets are used as they are.
+		if got := formatSelector(req); got != preset {
+			t.Errorf("formatSelector(%s, mkv) = %q, want %q", quality, got, preset)
+		}
+
+		req.Format = "mp4"
+		got := formatSelector(req)
+		if !strings.HasSuffix(got, preset) {
+			t.Errorf("formatSelector(%s, mp4) = %q, want it to fall back to %q", quality, got, preset)
+		}
+		if strings.HasPrefix(got, "-") {
+			t.Errorf("formatSelector(%s, mp4) = %q starts with -", quality, got)
+		}
+	}
+}
+
+func TestFormatSelectorOverrides(t *testing.T) {
+	tests := []struct {
+		name string
+		req  models.MergeRequest
+		want string
+	}{
+		{"selector", models.MergeRequest{Selector: "bv+ba", Quality: "720p"}, "bv+ba"},
+		{"format_id", models.MergeRequest{FormatID: "22", Quality: "720p"}, "22"},
+		{"audio", models.MergeRequest{Type: "audio"}, "bestaudio/best"},
+		{"smallest audio", models.MergeRequest{Type: "audio", Quality: "smallest"}, "worstaudio/worst"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatSelector(withMergeDefaults(tt.req)); got != tt.want {
+				t.Fatalf("formatSelector() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompatibleSelector(t *testing.T) {
+	tests := []struct {
+		name string
+		req  models.MergeRequest
+		want string
+	}{
+		{
+			"mp4 best",
+			models.MergeRequest{Type: "video", Format: "mp4", Quality: "best"},
+			"bestvideo[vcodec~='^(avc1|h264|hvc1|hev1|av01)']+bestaudio[acodec~='^(mp4a|aac|mp3)']/best[vcodec~='^(avc1|h264|hvc1|hev1|av01)'][acodec~='^(mp4a|aac|mp3)']",
+		},
+		{
+			"webm 720p",
+			models.MergeRequest{Type: "video", Format: "webm", Quality: "720p"},
+			"bestvideo[height<=720][vcodec~='^(vp8|vp9|vp09|av01)']+bestaudio[acodec~='^(opus|vorbis)']/best[height<=720][vcodec~='^(vp8|vp9|vp09|av01)'][acodec~='^(opus|vorbis)']",
+		},
+		{"mkv", models.MergeRequest{Type: "video", Format: "mkv", Quality: "best"}, ""},
+		{"remux", models.MergeRequest{Type: "video", Format: "mp4", Quality: "best", Conversion: conversionRemux}, ""},
+		{"audio", models.MergeRequest{Type: "audio", Format: "mp3", Quality: "best"}, ""},
+		{"h264-compatible", models.MergeRequest{Type: "video", Format: "mp4", Quality: "h264-compatible"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := compatibleSelector(tt.req); got != tt.want {
+				t.Fatalf("compatibleSelector() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"strings"
+	"time"
+
+	"github.com/pavelc4/ytdpl-api-go/config"
+)
+
+var ErrObjectNotFound = errors.New("object not found")
+
+// MediaPrefixes are the key prefixes merged files are stored under. Only
+// objects below them are cleaned up or exposed through the API.
+var MediaPrefixes = []string{"vidioe/", "audio/", batchPrefix}
+
+// IsMediaKey reports whether key lies below one of MediaPrefixes.
+func IsMediaKey(key string) bool {
+	for _, prefix := range MediaPrefixes {
+		if strings.HasPrefix(key, prefix) && len(key) > len(prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+var mediaContentTypes = map[string]string{
+	"mp4":  "video/mp4",
+	"mkv":  "video/x-matroska",
+	"webm": "video/webm",
+	"mp3":  "audio/mpeg",
+	"m4a":  "audio/mp4",
+	"aac":  "audio/aac",
+	"opus": "audio/ogg",
+	"ogg":  "audio/ogg",
+	"flac": "audio/flac",
+	"wav":  "audio/wav",
+	"vtt":  "text/vtt",
+	"zip":  "application/zip",
+}
+
+// ContentType returns the MIME type for a media file extension.
+func ContentType(ext string) string {
+	if contentType, ok := mediaContentTypes[strings.TrimPrefix(ext, ".")]; ok {
+		return contentType
+	}
+	if contentType := mime.TypeByExtension("." + strings.TrimPrefix(ext, ".")); contentType != "" {
+		return contentType
+	}
+	return "application/octet-stream"
+}
+
+// StorageObject describes a stored object as returned by Storage.List.
+type StorageObject struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+	Metadata     map[string]string
+}
+
+// Storage is where merged files end up. Upload returns the URL clients
+// should use to fetch the object, as URL does for existing objects.
+// Backends that cannot keep metadata ignore it.
+type Storage interface {
+	Upload(ctx context.Context, localPath, key string, metadata map[string]string) (string, error)
+	URL(ctx context.Context, key string) (string, error)
+	Download(ctx context.Context, key, destPath string) error
+	Delete(ctx context.Context, key string) error
+	List(ctx context.Context, prefix string) ([]StorageObject, error)
+	// Stat returns ErrObjectNotFound when key does not exist.
+	Stat(ctx context.Context, key string) (*StorageObject, error)
+	PresignGet(ctx context.Context, key string, ttl time.Duration) (string, error)
+}
+
+// ObjectCopier is implemented by storage backends that can copy an object to
+// a new key without passing its data through the API. The copy counts as
+// newly written for retention.
+type ObjectCopier interface {
+	Copy(ctx context.Context, srcKey, destKey string) (string, error)
+}
+
+// NewStorage builds the backend selected by cfg.StorageBackend.
+func NewStorage(cfg *config.Config) (Storage, error) {
+	switch cfg.StorageBackend {
+	case "r2", "s3":
+		r2Service, err := NewR2Service(cfg.R2Config)
+		if err != nil {
+			return nil, err
+		}
+		return r2Service, nil
+	case "local":
+		localStorage, err := NewLocalStorage(cfg.LocalStorage)
+		if err != nil {
+			return nil, err
+		}
+		return localStorage, nil
+	case "memory":
+		return NewMemoryStorage(), nil
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", cfg.StorageBackend)
+	}
+}
+
+// CleanupOldFiles deletes merged files older than retentionDays.
+func CleanupOldFiles(ctx context.Context, storage Storage, retentionDays int) error {
+	log.Printf(" Starting cleanup of files older than %d days...", retentionDays)
+
+	deletedCount := 0
+	errorsCount := 0
+	cutoff := time.Now().AddDate(0, 0, -retentionDays)
+
+	for _, prefix := range MediaPrefixes {
+		objects, err := storage.List(ctx, prefix)
+		if err != nil {
+			log.Printf("Failed to list objects for prefix %s: %v", prefix, err)
+			errorsCount++
+			continue
+		}
+
+		for _, obj := range objects {
+			if obj.LastModified.Before(cutoff) {
+				err := storage.Delete(ctx, obj.Key)
+				if err != nil {
+					log.Printf(" Failed to delete %s: %v", obj.Key, err)
+					errorsCount++
+				} else {
+					log.Printf(" Deleted old file: %s (Last modified: %s)", obj.Key, obj.LastModified.Format(time.RFC3339))
+					deletedCount++
+				}
+			}
+		}
+	}
+
+	log.Printf(" Cleanup completed. Deleted: %d, Errors: %d", deletedCount, errorsCount)
+	return nil
+}
@@ -0,0 +1,309 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pavelc4/ytdpl-api-go/config"
+)
+
+var (
+	ErrInvalidKey       = errors.New("invalid object key")
+	ErrLinkExpired      = errors.New("link expired")
+	ErrInvalidSignature = errors.New("invalid link signature")
+)
+
+// tempObjectPrefix names the files objects are written to before they are
+// renamed into place; List skips them.
+const tempObjectPrefix = ".upload-"
+
+// LocalStorage keeps objects in a directory on disk; the API serves them
+// under /files with HMAC-signed, expiring links. Object metadata is not kept.
+type LocalStorage struct {
+	dir        string
+	baseURL    string
+	signingKey []byte
+	linkTTL    time.Duration
+}
+
+func NewLocalStorage(cfg config.LocalStorageConfig) (*LocalStorage, error) {
+	if cfg.Dir == "" {
+		return nil, fmt.Errorf("local storage directory not configured")
+	}
+	if err := os.MkdirAll(cfg.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %w", err)
+	}
+
+	if cfg.SigningKey == "" {
+		return nil, fmt.Errorf("file signing key not configured")
+	}
+	signingKey := []byte(cfg.SigningKey)
+
+	return &LocalStorage{
+		dir:        cfg.Dir,
+		baseURL:    strings.TrimRight(cfg.BaseURL, "/"),
+		signingKey: signingKey,
+		linkTTL:    cfg.LinkTTL,
+	}, nil
+}
+
+// Dir is the directory objects are stored in.
+func (l *LocalStorage) Dir() string {
+	return l.dir
+}
+
+// Path maps an object key to its file, rejecting keys that would escape the
+// storage directory.
+func (l *LocalStorage) Path(key string) (string, error) {
+	clean := filepath.Clean("/" + key)
+	if clean == "/" {
+		return "", ErrInvalidKey
+	}
+	return filepath.Join(l.dir, filepath.FromSlash(clean)), nil
+}
+
+func (l *LocalStorage) Upload(ctx context.Context, localPath, key string, metadata map[string]string) (string, error) {
+	dest, err := l.Path(key)
+	if err != nil {
+		return "", err
+	}
+	in, err := os.Open(localPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to store file: %w", err)
+	}
+	defer in.Close()
+
+	if err := writeObject(dest, in); err != nil {
+		return "", fmt.Errorf("failed to store file: %w", err)
+	}
+
+	return l.URL(ctx, key)
+}
+
+func (l *LocalStorage) UploadStream(ctx context.Context, body io.Reader, key, contentType string, metadata map[string]string) (string, error) {
+	dest, err := l.Path(key)
+	if err != nil {
+		return "", err
+	}
+	if err := writeObject(dest, body); err != nil {
+		return "", fmt.Errorf("failed to store file: %w", err)
+	}
+
+	return l.URL(ctx, key)
+}
+
+// writeObject writes r to a temp file next to dest and renames it into
+// place once synced, so readers never see a partially written object.
+func writeObject(dest string, r io.Reader) error {
+	dir := filepath.Dir(dest)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create object directory: %w", err)
+	}
+
+	out, err := os.CreateTemp(dir, tempObjectPrefix+"*")
+	if err != nil {
+		return err
+	}
+	tmp := out.Name()
+
+	_, err = io.Copy(out, r)
+	if err == nil {
+		err = out.Sync()
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp, 0644)
+	}
+	if err == nil {
+		err = os.Rename(tmp, dest)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
+
+func (l *LocalStorage) Copy(ctx context.Context, srcKey, destKey string) (string, error) {
+	src, err := l.Path(srcKey)
+	if err != nil {
+		return "", err
+	}
+	dest, err := l.Path(destKey)
+	if err != nil {
+		return "", err
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", ErrObjectNotFound
+		}
+		return "", fmt.Errorf("failed to read stored file: %w", err)
+	}
+	defer in.Close()
+
+	if err := writeObject(dest, in); err != nil {
+		return "", fmt.Errorf("failed to store file: %w", err)
+	}
+
+	return l.URL(ctx, destKey)
+}
+
+func (l *LocalStorage) URL(ctx context.Context, key string) (string, error) {
+	return l.PresignGet(ctx, key, 0)
+}
+
+func (l *LocalStorage) Stat(ctx context.Context, key string) (*StorageObject, error) {
+	path, err := l.Path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrObjectNotFound
+		}
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, ErrObjectNotFound
+	}
+
+	return &StorageObject{
+		Key:          key,
+		Size:         info.Size(),
+		LastModified: info.ModTime(),
+	}, nil
+}
+
+func (l *LocalStorage) Download(ctx context.Context, key, destPath string) error {
+	src, err := l.Path(key)
+	if err != nil {
+		return err
+	}
+	if err := copyFile(src, destPath); err != nil {
+		return fmt.Errorf("failed to read stored file: %w", err)
+	}
+	return nil
+}
+
+func (l *LocalStorage) Delete(ctx context.Context, key string) error {
+	path, err := l.Path(key)
+	if err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+func (l *LocalStorage) List(ctx context.Context, prefix string) ([]StorageObject, error) {
+	var objects []StorageObject
+
+	err := filepath.WalkDir(l.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), tempObjectPrefix) {
+			return nil
+		}
+
+		rel, err := filepath.Rel(l.dir, path)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		objects = append(objects, StorageObject{
+			Key:          key,
+			Size:         info.Size(),
+			LastModified: info.ModTime(),
+		})
+		return nil
+	})
+	if err != nil {
+		return objects, fmt.Errorf("failed to list files: %w", err)
+	}
+
+	return objects, nil
+}
+
+// PresignGet returns a link to key that expires after ttl, or after the
+// configured link TTL when ttl is not positive.
+func (l *LocalStorage) PresignGet(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	if _, err := l.Path(key); err != nil {
+		return "", err
+	}
+	if ttl <= 0 {
+		ttl = l.linkTTL
+	}
+
+	expires := strconv.FormatInt(time.Now().Add(ttl).Unix(), 10)
+	query := url.Values{
+		"expires":   {expires},
+		"signature": {l.sign(key, expires)},
+	}
+	return fmt.Sprintf("%s/files/%s?%s", l.baseURL, key, query.Encode()), nil
+}
+
+// Verify checks a link produced by PresignGet.
+func (l *LocalStorage) Verify(key, expires, signature string) error {
+	expected := l.sign(key, expires)
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return ErrInvalidSignature
+	}
+
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if time.Now().Unix() > expiresAt {
+		return ErrLinkExpired
+	}
+	return nil
+}
+
+func (l *LocalStorage) sign(key, expires string) string {
+	mac := hmac.New(sha256.New, l.signingKey)
+	mac.Write([]byte(key + "\n" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func copyFile(src, dest string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
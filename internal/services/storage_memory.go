@@ -0,0 +1,132 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type memoryObject struct {
+	data         []byte
+	lastModified time.Time
+	metadata     map[string]string
+}
+
+// MemoryStorage keeps objects in process memory. It is meant for tests and
+// local experiments; URLs it returns are not fetchable.
+type MemoryStorage struct {
+	mu      sync.RWMutex
+	objects map[string]memoryObject
+}
+
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{
+		objects: make(map[string]memoryObject),
+	}
+}
+
+func (m *MemoryStorage) Upload(ctx context.Context, localPath, key string, metadata map[string]string) (string, error) {
+	data, err := os.ReadFile(localPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open file: %w", err)
+	}
+
+	m.mu.Lock()
+	m.objects[key] = memoryObject{data: data, lastModified: time.Now(), metadata: metadata}
+	m.mu.Unlock()
+
+	return m.URL(ctx, key)
+}
+
+func (m *MemoryStorage) UploadStream(ctx context.Context, body io.Reader, key, contentType string, metadata map[string]string) (string, error) {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read upload stream: %w", err)
+	}
+
+	m.mu.Lock()
+	m.objects[key] = memoryObject{data: data, lastModified: time.Now(), metadata: metadata}
+	m.mu.Unlock()
+
+	return m.URL(ctx, key)
+}
+
+func (m *MemoryStorage) Copy(ctx context.Context, srcKey, destKey string) (string, error) {
+	m.mu.Lock()
+	obj, ok := m.objects[srcKey]
+	if ok {
+		m.objects[destKey] = memoryObject{data: obj.data, lastModified: time.Now(), metadata: obj.metadata}
+	}
+	m.mu.Unlock()
+	if !ok {
+		return "", ErrObjectNotFound
+	}
+
+	return m.URL(ctx, destKey)
+}
+
+func (m *MemoryStorage) URL(ctx context.Context, key string) (string, error) {
+	return m.PresignGet(ctx, key, 0)
+}
+
+func (m *MemoryStorage) Stat(ctx context.Context, key string) (*StorageObject, error) {
+	m.mu.RLock()
+	obj, ok := m.objects[key]
+	m.mu.RUnlock()
+	if !ok {
+		return nil, ErrObjectNotFound
+	}
+
+	return &StorageObject{
+		Key:          key,
+		Size:         int64(len(obj.data)),
+		LastModified: obj.lastModified,
+		Metadata:     obj.metadata,
+	}, nil
+}
+
+func (m *MemoryStorage) Download(ctx context.Context, key, destPath string) error {
+	m.mu.RLock()
+	obj, ok := m.objects[key]
+	m.mu.RUnlock()
+	if !ok {
+		return ErrObjectNotFound
+	}
+
+	return os.WriteFile(destPath, obj.data, 0644)
+}
+
+func (m *MemoryStorage) Delete(ctx context.Context, key string) error {
+	m.mu.Lock()
+	delete(m.objects, key)
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *MemoryStorage) List(ctx context.Context, prefix string) ([]StorageObject, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var objects []StorageObject
+	for key, obj := range m.objects {
+		if strings.HasPrefix(key, prefix) {
+			objects = append(objects, StorageObject{
+				Key:          key,
+				Size:         int64(len(obj.data)),
+				LastModified: obj.lastModified,
+			})
+		}
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+
+	return objects, nil
+}
+
+func (m *MemoryStorage) PresignGet(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	return "memory://" + key, nil
+}
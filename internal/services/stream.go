@@ -0,0 +1,165 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/pavelc4/ytdpl-api-go/internal/models"
+)
+
+// StreamUploader is implemented by storage backends that can store an object
+// from a stream of unknown length without a local temp file.
+type StreamUploader interface {
+	UploadStream(ctx context.Context, body io.Reader, key, contentType string, metadata map[string]string) (string, error)
+}
+
+// streamMuxers lists the ffmpeg output options for every output that can be
+// written to a pipe. Plain MP4 needs a seekable output for its index, so it
+// is written fragmented.
+var streamMuxers = map[string][]string{
+	"video/mp4": {"-c", "copy", "-f", "mp4", "-movflags", "frag_keyframe+empty_moov+default_base_moof"},
+	"video/mkv": {"-c", "copy", "-f", "matroska"},
+	"audio/mp3": {"-vn", "-c:a", "libmp3lame", "-q:a", "0", "-f", "mp3"},
+}
+
+// CanStream reports whether a merge of req can be streamed straight into
+// storage. Audio is only streamed at the default quality and without
+// embedded tags, and video only when no stream needs transcoding.
+func CanStream(req models.MergeRequest) bool {
+	if req.Type == "audio" && (req.AudioQuality != "" || req.EmbedMetadata || req.EmbedThumbnail || req.Tags != nil) {
+		return false
+	}
+	if req.Conversion == conversionTranscode || len(req.Subtitles) > 0 || clips(req) {
+		return false
+	}
+	_, ok := streamMuxers[streamMuxerKey(req)]
+	return ok
+}
+
+func streamMuxerKey(req models.MergeRequest) string {
+	return req.Type + "/" + req.Format
+}
+
+type streamFormat struct {
+	URL            string            `json:"url"`
+	VCodec         string            `json:"vcodec"`
+	ACodec         string            `json:"acodec"`
+	HTTPHeaders    map[string]string `json:"http_headers"`
+	Filesize       int64             `json:"filesize"`
+	FilesizeApprox int64             `json:"filesize_approx"`
+}
+
+type streamInfo struct {
+	streamFormat
+	RequestedFormats []streamFormat `json:"requested_formats"`
+}
+
+// StreamTo resolves the selected formats with yt-dlp and muxes them with
+// ffmpeg into w, so nothing is written to disk. Callers must check
+// CanStream first. onProgress, if not nil, receives the number of bytes
+// written so far.
+func (s *YTDLPService) StreamTo(ctx context.Context, req models.MergeRequest, w io.Writer, onProgress func(models.Progress)) error {
+	muxer, ok := streamMuxers[streamMuxerKey(req)]
+	if !ok {
+		return fmt.Errorf("format %s cannot be streamed", req.Format)
+	}
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		return fmt.Errorf("ffmpeg not found: streaming requires ffmpeg in PATH")
+	}
+
+	_, inputs, err := s.resolveFormats(ctx, req)
+	if err != nil {
+		return err
+	}
+	if checksCodecs(req) {
+		if mismatch := codecMismatch(req, inputs); len(mismatch) > 0 {
+			return codecError(req, mismatch)
+		}
+	}
+
+	var ffmpegArgs []string
+	var total int64
+	ffmpegArgs = append(ffmpegArgs, "-hide_banner", "-loglevel", "error")
+	for _, f := range inputs {
+		if f.URL == "" {
+			return fmt.Errorf("no direct URL for selected format")
+		}
+		if headers := ffmpegHeaders(f.HTTPHeaders); headers != "" {
+			ffmpegArgs = append(ffmpegArgs, "-headers", headers)
+		}
+		ffmpegArgs = append(ffmpegArgs, "-i", f.URL)
+
+		if f.Filesize > 0 {
+			total += f.Filesize
+		} else {
+			total += f.FilesizeApprox
+		}
+	}
+	if len(inputs) > 1 {
+		ffmpegArgs = append(ffmpegArgs, "-map", "0:v:0", "-map", "1:a:0")
+	}
+	ffmpegArgs = append(ffmpegArgs, muxer...)
+	ffmpegArgs = append(ffmpegArgs, "pipe:1")
+
+	counter := &progressWriter{w: w, total: total, onProgress: onProgress}
+
+	return s.pool.Do(ctx, func() error {
+		var stderr bytes.Buffer
+		cmd := exec.CommandContext(ctx, "ffmpeg", ffmpegArgs...)
+		cmd.Stdout = counter
+		cmd.Stderr = &stderr
+
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("failed to stream: %w (output: %s)", err, stderr.String())
+		}
+		return nil
+	})
+}
+
+func ffmpegHeaders(headers map[string]string) string {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&b, "%s: %s\r\n", name, headers[name])
+	}
+	return b.String()
+}
+
+// progressWriter reports upload progress of a stream, at most once per MiB.
+type progressWriter struct {
+	w          io.Writer
+	total      int64
+	written    int64
+	onProgress func(models.Progress)
+}
+
+func (p *progressWriter) Write(b []byte) (int, error) {
+	n, err := p.w.Write(b)
+	if n > 0 && p.onProgress != nil {
+		before := p.written
+		p.written += int64(n)
+		after := p.written
+		if before>>20 != after>>20 {
+			progress := models.Progress{
+				Phase:           models.PhaseUpload,
+				DownloadedBytes: after,
+				TotalBytes:      p.total,
+			}
+			if p.total > 0 {
+				progress.Percent = min(float64(after)/float64(p.total)*100, 99)
+			}
+			p.onProgress(progress)
+		}
+	}
+	return n, err
+}
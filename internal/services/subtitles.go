@@ -0,0 +1,350 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/pavelc4/ytdpl-api-go/internal/models"
+)
+
+const (
+	subtitleEmbed    = "embed"
+	subtitleSidecar  = "sidecar"
+	maxSubtitleLangs = 10
+)
+
+var ErrSubtitleNotFound = errors.New("subtitle track not found")
+
+// subtitleFormats maps the formats /subtitles/download serves to the
+// --sub-format preference and --convert-subs target passed to yt-dlp. txt
+// is rendered from the WebVTT track.
+var subtitleFormats = map[string]struct{ preference, convert string }{
+	"vtt":   {"vtt/best", "vtt"},
+	"srt":   {"srt/vtt/best", "srt"},
+	"json3": {"json3", ""},
+	"txt":   {"vtt/best", "vtt"},
+}
+
+// subtitleCodecs are the codecs subtitles embedded in each container are
+// carried in when a video is transcoded.
+var subtitleCodecs = map[string]string{
+	"mp4":  "mov_text",
+	"mov":  "mov_text",
+	"webm": "webvtt",
+	"mkv":  "copy",
+}
+
+var (
+	subtitleLangPattern = regexp.MustCompile(`^[A-Za-z0-9]+(-[A-Za-z0-9]+)*$`)
+	subtitleFilePattern = regexp.MustCompile(`\.(vtt|srt|ass|lrc|ttml|srv[1-3]|json3)$`)
+)
+
+// GetSubtitles lists the subtitle and automatic caption languages of a
+// video, sorted by language code.
+func (s *YTDLPService) GetSubtitles(ctx context.Context, url string) (*models.SubtitlesResponse, error) {
+	data, err := s.Extract(ctx, url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract subtitles: %w", err)
+	}
+
+	return &models.SubtitlesResponse{
+		VideoID:           data.ID,
+		Subtitles:         subtitleLanguages(data.Subtitles, false),
+		AutomaticCaptions: subtitleLanguages(data.AutomaticCaptions, true),
+	}, nil
+}
+
+func subtitleLanguages(tracks map[string][]models.SubtitleTrack, automatic bool) []models.SubtitleLanguage {
+	languages := []models.SubtitleLanguage{}
+	for lang, formats := range tracks {
+		// YouTube lists the chat replay of live streams as a subtitle.
+		if lang == "live_chat" {
+			continue
+		}
+
+		language := models.SubtitleLanguage{Lang: lang, Automatic: automatic, Formats: []string{}}
+		for _, f := range formats {
+			if language.Name == "" {
+				language.Name = f.Name
+			}
+			language.Formats = append(language.Formats, f.Ext)
+		}
+		languages = append(languages, language)
+	}
+	sort.Slice(languages, func(i, j int) bool { return languages[i].Lang < languages[j].Lang })
+	return languages
+}
+
+// DownloadSubtitle fetches one subtitle track in format. Subtitles are
+// preferred over automatic captions for lang unless auto is set. It returns
+// the track and its file name.
+func (s *YTDLPService) DownloadSubtitle(ctx context.Context, url, lang, format string, auto bool) ([]byte, string, error) {
+	if _, ok := subtitleFormats[format]; !ok {
+		return nil, "", fmt.Errorf("%w: unsupported subtitle format %q, use one of %s", ErrInvalidRequest, format, strings.Join(subtitleFormatNames(), ", "))
+	}
+
+	ext := format
+	if format == "txt" {
+		ext = "vtt"
+	}
+	track, err := s.fetchSubtitle(ctx, url, lang, ext, auto)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if format == "txt" {
+		cues := parseVTT(string(track.data))
+		if track.automatic {
+			cues = cleanCues(cues)
+		}
+		track.data = []byte(vttText(cues))
+	}
+
+	return track.data, fmt.Sprintf("%s.%s.%s", track.videoID, lang, format), nil
+}
+
+type subtitleData struct {
+	videoID   string
+	automatic bool
+	chapters  []models.Chapter
+	data      []byte
+}
+
+// fetchSubtitle has yt-dlp write the lang track of url as ext, which must be
+// a key of subtitleFormats other than txt.
+func (s *YTDLPService) fetchSubtitle(ctx context.Context, url, lang, ext string, auto bool) (*subtitleData, error) {
+	target := subtitleFormats[ext]
+	if !subtitleLangPattern.MatchString(lang) {
+		return nil, fmt.Errorf("%w: invalid subtitle language %q", ErrInvalidRequest, lang)
+	}
+
+	data, err := s.Extract(ctx, url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract subtitles: %w", err)
+	}
+
+	tracks, automatic := data.Subtitles[lang], false
+	if len(tracks) == 0 || auto {
+		tracks, automatic = data.AutomaticCaptions[lang], true
+	}
+	if len(tracks) == 0 {
+		return nil, fmt.Errorf("%w: no %s subtitles for %s", ErrSubtitleNotFound, lang, data.ID)
+	}
+	if target.convert == "" && !subtitleOffered(tracks, ext) {
+		return nil, fmt.Errorf("%w: %s subtitles are not offered as %s", ErrSubtitleNotFound, lang, ext)
+	}
+	convert := target.convert != "" && !subtitleOffered(tracks, target.convert)
+	if convert {
+		if _, err := exec.LookPath("ffmpeg"); err != nil {
+			return nil, fmt.Errorf("ffmpeg not found: subtitle conversion requires ffmpeg in PATH")
+		}
+	}
+
+	tmpDir := filepath.Join(os.TempDir(), "ytdpl")
+	if err := os.MkdirAll(tmpDir, 0755); err != nil {
+		return nil, err
+	}
+	outputBase := filepath.Join(tmpDir, uuid.New().String())
+	defer func() {
+		matches, _ := filepath.Glob(outputBase + ".*")
+		for _, path := range matches {
+			os.Remove(path)
+		}
+	}()
+
+	args := []string{"--skip-download"}
+	if automatic {
+		args = append(args, "--write-auto-subs")
+	} else {
+		args = append(args, "--write-subs")
+	}
+	args = append(args, "--sub-langs", lang, "--sub-format", target.preference)
+	if convert {
+		args = append(args, "--convert-subs", target.convert)
+	}
+	args = append(args,
+		"--no-playlist",
+		"--no-warnings",
+		"--no-cache-dir",
+		"-o", outputBase+".%(ext)s",
+	)
+
+	if path, err := exec.LookPath("bun"); err == nil && path != "" {
+		args = append(args, "--js-runtimes", "bun")
+	}
+
+	if s.cookiePath != "" {
+		args = append(args, "--cookies", s.cookiePath)
+	}
+
+	args = append(args, url)
+
+	output, err := s.run(ctx, args)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download subtitles: %w (output: %s)", err, string(output))
+	}
+
+	track, err := os.ReadFile(outputBase + "." + lang + "." + ext)
+	if err != nil {
+		return nil, fmt.Errorf("yt-dlp produced no %s subtitles: %w", ext, err)
+	}
+
+	return &subtitleData{
+		videoID:   data.ID,
+		automatic: automatic,
+		chapters:  data.Chapters,
+		data:      track,
+	}, nil
+}
+
+// SubtitleContentType returns the MIME type of a /subtitles/download format.
+func SubtitleContentType(format string) string {
+	switch format {
+	case "srt":
+		return "application/x-subrip; charset=utf-8"
+	case "json3":
+		return "application/json"
+	case "txt":
+		return "text/plain; charset=utf-8"
+	default:
+		return "text/vtt; charset=utf-8"
+	}
+}
+
+func subtitleOffered(tracks []models.SubtitleTrack, ext string) bool {
+	for _, t := range tracks {
+		if t.Ext == ext {
+			return true
+		}
+	}
+	return false
+}
+
+func validateSubtitles(req models.MergeRequest) error {
+	if len(req.Subtitles) == 0 {
+		if req.SubtitleMode != "" || req.AutoSubtitles {
+			return fmt.Errorf("%w: subtitle options require subtitles", ErrInvalidRequest)
+		}
+		return nil
+	}
+
+	if req.Type != "video" {
+		return fmt.Errorf("%w: subtitles are only supported for video", ErrInvalidRequest)
+	}
+	if len(req.Subtitles) > maxSubtitleLangs {
+		return fmt.Errorf("%w: at most %d subtitle languages", ErrInvalidRequest, maxSubtitleLangs)
+	}
+	for _, lang := range req.Subtitles {
+		if !subtitleLangPattern.MatchString(lang) {
+			return fmt.Errorf("%w: invalid subtitle language %q", ErrInvalidRequest, lang)
+		}
+	}
+	if req.SubtitleMode != subtitleEmbed && req.SubtitleMode != subtitleSidecar {
+		return fmt.Errorf("%w: subtitle_mode must be %s or %s", ErrInvalidRequest, subtitleEmbed, subtitleSidecar)
+	}
+	return nil
+}
+
+// subtitleArgs asks yt-dlp for the requested tracks, either embedded in the
+// output or written next to it as WebVTT.
+func subtitleArgs(req models.MergeRequest) []string {
+	if len(req.Subtitles) == 0 {
+		return nil
+	}
+
+	args := []string{"--write-subs"}
+	if req.AutoSubtitles {
+		args = append(args, "--write-auto-subs")
+	}
+	args = append(args, "--sub-langs", strings.Join(req.Subtitles, ","), "--sub-format", "vtt/best")
+	if req.SubtitleMode == subtitleEmbed {
+		return append(args, "--embed-subs")
+	}
+	return append(args, "--convert-subs", "vtt")
+}
+
+// embedsSubtitles reports whether req carries subtitle streams in the output.
+func embedsSubtitles(req models.MergeRequest) bool {
+	return len(req.Subtitles) > 0 && req.SubtitleMode == subtitleEmbed
+}
+
+// findSubtitles returns the sidecar tracks yt-dlp wrote next to outputBase,
+// keyed by language.
+func findSubtitles(outputBase string) map[string]string {
+	matches, _ := filepath.Glob(outputBase + ".*.vtt")
+	tracks := make(map[string]string, len(matches))
+	for _, match := range matches {
+		lang := strings.TrimSuffix(strings.TrimPrefix(match, outputBase+"."), ".vtt")
+		if subtitleLangPattern.MatchString(lang) {
+			tracks[lang] = match
+		}
+	}
+	return tracks
+}
+
+// uploadSubtitles stores sidecar tracks next to the object at objectKey, as
+// <key without extension>.<lang>.vtt.
+func (m *JobManager) uploadSubtitles(ctx context.Context, tracks map[string]string, objectKey string) ([]models.SubtitleFile, error) {
+	base := strings.TrimSuffix(objectKey, filepath.Ext(objectKey))
+
+	langs := make([]string, 0, len(tracks))
+	for lang := range tracks {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+
+	files := make([]models.SubtitleFile, 0, len(langs))
+	for _, lang := range langs {
+		key := base + "." + lang + ".vtt"
+		publicURL, err := m.storage.Upload(ctx, tracks[lang], key, map[string]string{})
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, models.SubtitleFile{Lang: lang, URL: publicURL, Key: key})
+	}
+	return files, nil
+}
+
+// storedSubtitles finds the sidecar tracks of an existing object, for
+// results that reuse it.
+func (m *JobManager) storedSubtitles(ctx context.Context, objectKey string) []models.SubtitleFile {
+	base := strings.TrimSuffix(objectKey, filepath.Ext(objectKey))
+	objects, err := m.storage.List(ctx, base+".")
+	if err != nil {
+		log.Printf(" Failed to list subtitles of %s: %v", objectKey, err)
+		return nil
+	}
+
+	var files []models.SubtitleFile
+	for _, obj := range objects {
+		lang, ok := strings.CutSuffix(strings.TrimPrefix(obj.Key, base+"."), ".vtt")
+		if !ok || !subtitleLangPattern.MatchString(lang) {
+			continue
+		}
+		publicURL, err := m.storage.URL(ctx, obj.Key)
+		if err != nil {
+			log.Printf(" Failed to create link for %s: %v", obj.Key, err)
+			continue
+		}
+		files = append(files, models.SubtitleFile{Lang: lang, URL: publicURL, Key: obj.Key})
+	}
+	return files
+}
+
+func subtitleFormatNames() []string {
+	names := make([]string, 0, len(subtitleFormats))
+	for name := range subtitleFormats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
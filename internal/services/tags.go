@@ -0,0 +1,111 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/pavelc4/ytdpl-api-go/internal/models"
+)
+
+const maxTagLength = 256
+
+// thumbnailFormats are the audio formats yt-dlp can embed cover art into.
+// aac is written as a raw ADTS stream, which has nowhere to put it.
+var thumbnailFormats = []string{"mp3", "m4a", "opus", "vorbis", "flac"}
+
+var (
+	tagDatePattern  = regexp.MustCompile(`^[0-9]{4}(-?[0-9]{2}-?[0-9]{2})?$`)
+	tagTrackPattern = regexp.MustCompile(`^[0-9]{1,4}(/[0-9]{1,4})?$`)
+)
+
+func validateTags(req models.MergeRequest) error {
+	if !req.EmbedMetadata && !req.EmbedThumbnail && req.Tags == nil {
+		return nil
+	}
+	if req.Type != "audio" {
+		return fmt.Errorf("%w: metadata and thumbnail embedding require type=audio", ErrInvalidRequest)
+	}
+	if req.EmbedThumbnail {
+		if req.Format != audioOriginal && !slices.Contains(thumbnailFormats, req.Format) {
+			return fmt.Errorf("%w: format %s cannot hold cover art, use one of %s", ErrInvalidRequest, req.Format, strings.Join(thumbnailFormats, ", "))
+		}
+	}
+	if req.Tags == nil {
+		return nil
+	}
+
+	for _, tag := range req.Tags.Fields() {
+		if len(tag.Value) > maxTagLength {
+			return fmt.Errorf("%w: tag %s longer than %d characters", ErrInvalidRequest, tag.Name, maxTagLength)
+		}
+		if strings.ContainsFunc(tag.Value, unicode.IsControl) {
+			return fmt.Errorf("%w: tag %s contains control characters", ErrInvalidRequest, tag.Name)
+		}
+	}
+	if req.Tags.Date != "" && !tagDatePattern.MatchString(req.Tags.Date) {
+		return fmt.Errorf("%w: date must be YYYY, YYYYMMDD or YYYY-MM-DD", ErrInvalidRequest)
+	}
+	if req.Tags.Track != "" && !tagTrackPattern.MatchString(req.Tags.Track) {
+		return fmt.Errorf("%w: track must be a number, optionally followed by /total", ErrInvalidRequest)
+	}
+	return nil
+}
+
+// embedArgs returns the yt-dlp options that write the video's own metadata
+// and thumbnail into the file. Tag overrides are applied afterwards by
+// applyTags.
+func embedArgs(req models.MergeRequest) []string {
+	var args []string
+	if req.EmbedMetadata || req.Tags != nil {
+		args = append(args, "--embed-metadata")
+	}
+	if req.EmbedThumbnail {
+		// Not every container takes webp cover art, which YouTube serves.
+		args = append(args, "--embed-thumbnail", "--convert-thumbnails", "jpg")
+	}
+	return args
+}
+
+// applyTags rewrites the tags of the file at path with ffmpeg, copying all
+// streams (including embedded cover art) unchanged.
+func (s *YTDLPService) applyTags(ctx context.Context, path string, tags *models.AudioTags) error {
+	if tags == nil {
+		return nil
+	}
+	fields := tags.Fields()
+	if len(fields) == 0 {
+		return nil
+	}
+
+	ext := filepath.Ext(path)
+	tagged := strings.TrimSuffix(path, ext) + ".tagged" + ext
+
+	args := []string{"-hide_banner", "-loglevel", "error", "-y", "-i", path, "-map", "0", "-c", "copy"}
+	for _, tag := range fields {
+		args = append(args, "-metadata", tag.Name+"="+tag.Value)
+	}
+	args = append(args, tagged)
+
+	err := s.pool.Do(ctx, func() error {
+		var stderr bytes.Buffer
+		cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("failed to write tags: %w (output: %s)", err, stderr.String())
+		}
+		return nil
+	})
+	if err != nil {
+		os.Remove(tagged)
+		return err
+	}
+	return os.Rename(tagged, path)
+}
@@ -0,0 +1,110 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/pavelc4/ytdpl-api-go/internal/models"
+)
+
+// transcriptCache is what GetTranscript caches per video, language and
+// source; chapters are grouped on every request.
+type transcriptCache struct {
+	Transcript models.Transcript `json:"transcript"`
+	Chapters   []models.Chapter  `json:"chapters"`
+}
+
+// GetTranscript turns the lang captions of url into timed plain-text
+// segments. Subtitles are preferred over automatic captions unless auto is
+// set; with byChapter the segments are also grouped by chapter.
+func (s *YTDLPService) GetTranscript(ctx context.Context, url, lang string, auto, byChapter bool) (*models.Transcript, error) {
+	cacheKey := fmt.Sprintf("transcript_%s_%s_%t", s.CacheKey(url), lang, auto)
+
+	var cached transcriptCache
+	if !s.cacheGet(ctx, cacheKey, &cached) {
+		track, err := s.fetchSubtitle(ctx, url, lang, "vtt", auto)
+		if err != nil {
+			return nil, err
+		}
+
+		cues := parseVTT(string(track.data))
+		if track.automatic {
+			cues = cleanCues(cues)
+		}
+
+		cached = transcriptCache{
+			Transcript: models.Transcript{
+				VideoID:   track.videoID,
+				Lang:      lang,
+				Automatic: track.automatic,
+				Segments:  transcriptSegments(cues),
+			},
+			Chapters: track.chapters,
+		}
+		cached.Transcript.Text = transcriptText(cached.Transcript.Segments)
+		s.cacheSet(ctx, cacheKey, &cached, extractionTTL)
+	}
+
+	transcript := cached.Transcript
+	if byChapter && len(cached.Chapters) > 0 {
+		transcript.Chapters = groupByChapter(transcript.Segments, cached.Chapters)
+	}
+	return &transcript, nil
+}
+
+func transcriptSegments(cues []vttCue) []models.TranscriptSegment {
+	segments := []models.TranscriptSegment{}
+	for _, cue := range cues {
+		if len(cue.lines) == 0 {
+			continue
+		}
+		segments = append(segments, models.TranscriptSegment{
+			Start: roundMillis(cue.start),
+			End:   roundMillis(cue.end),
+			Text:  strings.Join(cue.lines, " "),
+		})
+	}
+	return segments
+}
+
+func transcriptText(segments []models.TranscriptSegment) string {
+	texts := make([]string, len(segments))
+	for i, segment := range segments {
+		texts[i] = segment.Text
+	}
+	return strings.Join(texts, " ")
+}
+
+// groupByChapter assigns each segment to the chapter it starts in. Segments
+// starting before the first chapter belong to it, as do those after the
+// last one to the last.
+func groupByChapter(segments []models.TranscriptSegment, chapters []models.Chapter) []models.TranscriptChapter {
+	grouped := make([]models.TranscriptChapter, len(chapters))
+	for i, chapter := range chapters {
+		grouped[i] = models.TranscriptChapter{
+			Title:    chapter.Title,
+			Start:    chapter.StartTime,
+			End:      chapter.EndTime,
+			Segments: []models.TranscriptSegment{},
+		}
+	}
+
+	current := 0
+	for _, segment := range segments {
+		for current < len(chapters)-1 && segment.Start >= chapters[current+1].StartTime {
+			current++
+		}
+		grouped[current].Segments = append(grouped[current].Segments, segment)
+	}
+
+	for i := range grouped {
+		grouped[i].Text = transcriptText(grouped[i].Segments)
+	}
+	return grouped
+}
+
+func roundMillis(seconds float64) float64 {
+	return math.Round(seconds*1000) / 1000
+}
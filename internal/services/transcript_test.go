@@ -0,0 +1,69 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/pavelc4/ytdpl-api-go/internal/models"
+)
+
+func TestTranscriptSegments(t *testing.T) {
+	cues := []vttCue{
+		{start: 0.0004, end: 1.2345, lines: []string{"first", "line"}},
+		{start: 2, end: 3},
+		{start: 3, end: 4.5, lines: []string{"second"}},
+	}
+
+	got := transcriptSegments(cues)
+	want := []models.TranscriptSegment{
+		{Start: 0, End: 1.235, Text: "first line"},
+		{Start: 3, End: 4.5, Text: "second"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("transcriptSegments() = %+v, want %+v", got, want)
+	}
+	if text := transcriptText(got); text != "first line second" {
+		t.Fatalf("transcriptText() = %q", text)
+	}
+
+	if got := transcriptSegments(nil); got == nil || len(got) != 0 {
+		t.Fatalf("transcriptSegments(nil) = %#v, want an empty slice", got)
+	}
+}
+
+func TestTranscriptOfRollingCaptions(t *testing.T) {
+	got := transcriptSegments(cleanCues(parseVTT(rollingCaptions)))
+	want := []models.TranscriptSegment{
+		{Start: 0, End: 2.01, Text: "hello world"},
+		{Start: 2.01, End: 4.01, Text: "this is a test"},
+		{Start: 4.01, End: 6, Text: "and more"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("segments = %+v, want %+v", got, want)
+	}
+}
+
+func TestGroupByChapter(t *testing.T) {
+	chapters := []models.Chapter{
+		{Title: "Intro", StartTime: 5, EndTime: 10},
+		{Title: "Empty", StartTime: 10, EndTime: 12},
+		{Title: "Main", StartTime: 12, EndTime: 20},
+	}
+	segments := []models.TranscriptSegment{
+		{Start: 0, End: 2, Text: "before"},
+		{Start: 6, End: 9, Text: "intro"},
+		{Start: 11.5, End: 13, Text: "straddles"},
+		{Start: 12, End: 15, Text: "main"},
+		{Start: 25, End: 26, Text: "after"},
+	}
+
+	got := groupByChapter(segments, chapters)
+	want := []models.TranscriptChapter{
+		{Title: "Intro", Start: 5, End: 10, Text: "before intro", Segments: segments[:2]},
+		{Title: "Empty", Start: 10, End: 12, Text: "straddles", Segments: segments[2:3]},
+		{Title: "Main", Start: 12, End: 20, Text: "main after", Segments: segments[3:]},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("groupByChapter() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,139 @@
+package services
+
+import (
+	"html"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// vttCue is a WebVTT cue reduced to plain text, one entry per line.
+type vttCue struct {
+	start float64
+	end   float64
+	lines []string
+}
+
+var vttTagPattern = regexp.MustCompile(`<[^>]*>`)
+
+// parseVTT reads the cues of a WebVTT file, dropping header, NOTE, STYLE and
+// REGION blocks as well as styling and karaoke timestamp tags.
+func parseVTT(data string) []vttCue {
+	data = strings.ReplaceAll(data, "\r\n", "\n")
+	data = strings.ReplaceAll(data, "\r", "\n")
+
+	var cues []vttCue
+	for _, block := range strings.Split(data, "\n\n") {
+		lines := strings.Split(strings.Trim(block, "\n"), "\n")
+
+		timing := -1
+		for i, line := range lines {
+			if strings.Contains(line, "-->") {
+				timing = i
+				break
+			}
+		}
+		if timing < 0 {
+			continue
+		}
+
+		start, end, ok := parseVTTTiming(lines[timing])
+		if !ok {
+			continue
+		}
+
+		cue := vttCue{start: start, end: end}
+		for _, line := range lines[timing+1:] {
+			text := strings.TrimSpace(html.UnescapeString(vttTagPattern.ReplaceAllString(line, "")))
+			text = strings.Join(strings.Fields(text), " ")
+			if text != "" {
+				cue.lines = append(cue.lines, text)
+			}
+		}
+		cues = append(cues, cue)
+	}
+	return cues
+}
+
+// parseVTTTiming parses "00:01:02.500 --> 00:01:04.000 align:start".
+func parseVTTTiming(line string) (float64, float64, bool) {
+	from, rest, ok := strings.Cut(line, "-->")
+	if !ok {
+		return 0, 0, false
+	}
+	fields := strings.Fields(rest)
+	if len(fields) == 0 {
+		return 0, 0, false
+	}
+	start, ok := parseVTTTimestamp(strings.TrimSpace(from))
+	if !ok {
+		return 0, 0, false
+	}
+	end, ok := parseVTTTimestamp(fields[0])
+	if !ok {
+		return 0, 0, false
+	}
+	return start, end, true
+}
+
+// parseVTTTimestamp parses "hh:mm:ss.ttt" or "mm:ss.ttt" into seconds.
+func parseVTTTimestamp(s string) (float64, bool) {
+	parts := strings.Split(strings.Replace(s, ",", ".", 1), ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, false
+	}
+
+	var seconds float64
+	for _, part := range parts {
+		n, err := strconv.ParseFloat(part, 64)
+		if err != nil || n < 0 {
+			return 0, false
+		}
+		seconds = seconds*60 + n
+	}
+	return seconds, true
+}
+
+// cleanCues collapses the rolling captions of automatic subtitles, where each
+// cue repeats the line(s) shown by the previous one before adding a new line,
+// and drops cues that add nothing. Each returned cue holds only new text; a
+// cue that repeats text extends the previous one instead.
+func cleanCues(cues []vttCue) []vttCue {
+	var cleaned []vttCue
+	var shown []string
+
+	for _, cue := range cues {
+		var fresh []string
+		for _, line := range cue.lines {
+			if len(fresh) == 0 && slices.Contains(shown, line) {
+				continue
+			}
+			fresh = append(fresh, line)
+		}
+
+		if len(fresh) == 0 {
+			if n := len(cleaned); n > 0 && cue.end > cleaned[n-1].end {
+				cleaned[n-1].end = cue.end
+			}
+			continue
+		}
+
+		cleaned = append(cleaned, vttCue{start: cue.start, end: cue.end, lines: fresh})
+		shown = append(shown, fresh...)
+		if len(shown) > 3 {
+			shown = shown[len(shown)-3:]
+		}
+	}
+	return cleaned
+}
+
+// vttText renders cues as plain text, one line per cue.
+func vttText(cues []vttCue) string {
+	var b strings.Builder
+	for _, cue := range cues {
+		b.WriteString(strings.Join(cue.lines, " "))
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
@@ -0,0 +1,165 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+)
+
+// rollingCaptions mimics YouTube's automatic captions: every cue repeats the
+// line shown before it, word timings are inlined as tags, and 10ms cues
+// freeze the previous text between lines.
+const rollingCaptions = "WEBVTT\n" +
+	"Kind: captions\n" +
+	"Language: en\n" +
+	"\n" +
+	"00:00:00.000 --> 00:00:02.000 align:start position:0%\n" +
+	"hello<00:00:00.500><c> world</c>\n" +
+	"\n" +
+	"00:00:02.000 --> 00:00:02.010 align:start position:0%\n" +
+	"hello world\n" +
+	" \n" +
+	"\n" +
+	"00:00:02.010 --> 00:00:04.000 align:start position:0%\n" +
+	"hello world\n" +
+	"this<00:00:02.500><c> is</c><00:00:03.000><c> a test</c>\n" +
+	"\n" +
+	"00:00:04.000 --> 00:00:04.010 align:start position:0%\n" +
+	"this is a test\n" +
+	" \n" +
+	"\n" +
+	"00:00:04.010 --> 00:00:06.000 align:start position:0%\n" +
+	"this is a test\n" +
+	"and<00:00:04.500><c> more</c>\n"
+
+func TestParseVTT(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want []vttCue
+	}{
+		{
+			name: "header, notes and styles",
+			data: "WEBVTT - title\n\nNOTE a comment\nspanning lines\n\nSTYLE\n::cue { color: red }\n\n" +
+				"1\n00:01.000 --> 00:02.500\n<v Roger>Hi &amp; <b>welcome</b></v>\n\n" +
+				"intro\n01:00:00.000 --> 01:00:01.000 line:0\nsecond   line\nthird\n",
+			want: []vttCue{
+				{start: 1, end: 2.5, lines: []string{"Hi & welcome"}},
+				{start: 3600, end: 3601, lines: []string{"second line", "third"}},
+			},
+		},
+		{
+			name: "crlf and comma decimals",
+			data: "WEBVTT\r\n\r\n00:00:01,500 --> 00:00:03,000\r\nline one\r\n",
+			want: []vttCue{{start: 1.5, end: 3, lines: []string{"line one"}}},
+		},
+		{
+			name: "invalid timings are skipped",
+			data: "WEBVTT\n\n00:00:xx --> 00:00:01.000\nbad\n\n00:00:01.000 -->\nbad\n\n00:00:02.000 --> 00:00:03.000\ngood\n",
+			want: []vttCue{{start: 2, end: 3, lines: []string{"good"}}},
+		},
+		{
+			name: "cue without text",
+			data: "WEBVTT\n\n00:00:00.000 --> 00:00:01.000\n<c> </c>\n",
+			want: []vttCue{{start: 0, end: 1}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseVTT(tt.data); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("parseVTT() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCleanCues(t *testing.T) {
+	tests := []struct {
+		name string
+		cues []vttCue
+		want []vttCue
+	}{
+		{
+			name: "rolling captions",
+			cues: parseVTT(rollingCaptions),
+			want: []vttCue{
+				{start: 0, end: 2.01, lines: []string{"hello world"}},
+				{start: 2.01, end: 4.01, lines: []string{"this is a test"}},
+				{start: 4.01, end: 6, lines: []string{"and more"}},
+			},
+		},
+		{
+			name: "two carried lines",
+			cues: []vttCue{
+				{start: 0, end: 1, lines: []string{"a"}},
+				{start: 1, end: 2, lines: []string{"b"}},
+				{start: 2, end: 3, lines: []string{"a", "b", "c"}},
+			},
+			want: []vttCue{
+				{start: 0, end: 1, lines: []string{"a"}},
+				{start: 1, end: 2, lines: []string{"b"}},
+				{start: 2, end: 3, lines: []string{"c"}},
+			},
+		},
+		{
+			name: "repeats after new text are kept",
+			cues: []vttCue{
+				{start: 0, end: 1, lines: []string{"yes"}},
+				{start: 1, end: 2, lines: []string{"no", "yes"}},
+			},
+			want: []vttCue{
+				{start: 0, end: 1, lines: []string{"yes"}},
+				{start: 1, end: 2, lines: []string{"no", "yes"}},
+			},
+		},
+		{
+			name: "text repeated long after is new again",
+			cues: []vttCue{
+				{start: 0, end: 1, lines: []string{"again"}},
+				{start: 1, end: 2, lines: []string{"b"}},
+				{start: 2, end: 3, lines: []string{"c"}},
+				{start: 3, end: 4, lines: []string{"d"}},
+				{start: 4, end: 5, lines: []string{"again"}},
+			},
+			want: []vttCue{
+				{start: 0, end: 1, lines: []string{"again"}},
+				{start: 1, end: 2, lines: []string{"b"}},
+				{start: 2, end: 3, lines: []string{"c"}},
+				{start: 3, end: 4, lines: []string{"d"}},
+				{start: 4, end: 5, lines: []string{"again"}},
+			},
+		},
+		{
+			name: "empty cues extend the previous one",
+			cues: []vttCue{
+				{start: 0, end: 1, lines: []string{"a"}},
+				{start: 1, end: 1.5},
+				{start: 1.5, end: 1.2, lines: []string{"a"}},
+			},
+			want: []vttCue{
+				{start: 0, end: 1.5, lines: []string{"a"}},
+			},
+		},
+		{
+			name: "leading empty cue",
+			cues: []vttCue{{start: 0, end: 1}},
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cleanCues(tt.cues); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("cleanCues() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVTTText(t *testing.T) {
+	got := vttText(cleanCues(parseVTT(rollingCaptions)))
+	want := "hello world\nthis is a test\nand more\n"
+	if got != want {
+		t.Fatalf("vttText() = %q, want %q", got, want)
+	}
+}
@@ -1,65 +1,114 @@
 package services
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
-
 	"time"
 
-	"github.com/patrickmn/go-cache"
+	"github.com/pavelc4/ytdpl-api-go/internal/cache"
 	models "github.com/pavelc4/ytdpl-api-go/internal/models"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	extractTimeout = 2 * time.Minute
+	extractionTTL  = 15 * time.Minute
+	canonicalIDTTL = 24 * time.Hour
+	// urlExpiryMargin is how long before their embedded expiry direct URLs
+	// stop being served from cache, leaving clients time to start a download.
+	urlExpiryMargin = 5 * time.Minute
+	// freshExtractionAge is how old an extraction may be and still count as
+	// fresh, so GetDownloadURLs does not extract it again.
+	freshExtractionAge = 1 * time.Minute
 )
 
 type YTDLPService struct {
-	cookiePath string
-	cache      *cache.Cache
-	semaphore  chan struct{}
+	cookiePath  string
+	cache       cache.Cache
+	pool        *WorkerPool
+	extractions singleflight.Group
 }
 
-func NewYTDLPService(cookiePath string) *YTDLPService {
+// NewYTDLPService creates a service whose yt-dlp processes all run on pool,
+// which bounds how many of them may execute at once. Extractions are kept in
+// c, which may be shared with other replicas.
+func NewYTDLPService(cookiePath string, pool *WorkerPool, c cache.Cache) *YTDLPService {
 	return &YTDLPService{
 		cookiePath: cookiePath,
-		cache:      cache.New(15*time.Minute, 30*time.Minute),
-		semaphore:  make(chan struct{}, 10), // Limit to 10 concurrent processes
+		cache:      c,
+		pool:       pool,
 	}
 }
 
-func (s *YTDLPService) GetDownloadURLs(ctx context.Context, url string) (*models.VideoURL, error) {
-	cacheKey := "dl_" + url
-	if cached, found := s.cache.Get(cacheKey); found {
-		return cached.(*models.VideoURL), nil
+// cacheGet reads key into dst and reports whether it was found. Cache
+// errors are logged and treated as misses so an unreachable Redis only
+// costs extra yt-dlp runs.
+func (s *YTDLPService) cacheGet(ctx context.Context, key string, dst any) bool {
+	err := s.cache.Get(ctx, key, dst)
+	if err != nil && !errors.Is(err, cache.ErrMiss) {
+		log.Printf(" Cache read failed for %s: %v", key, err)
 	}
+	return err == nil
+}
 
-	select {
-	case s.semaphore <- struct{}{}:
-		defer func() { <-s.semaphore }()
-	case <-ctx.Done():
-		return nil, ctx.Err()
+func (s *YTDLPService) cacheSet(ctx context.Context, key string, value any, ttl time.Duration) {
+	if err := s.cache.Set(ctx, key, value, ttl); err != nil {
+		log.Printf(" Cache write failed for %s: %v", key, err)
 	}
+}
 
-	args := []string{"-g", "--no-warnings", "--no-cache-dir", "--no-playlist"}
-
-	if path, err := exec.LookPath("bun"); err == nil && path != "" {
-		args = append(args, "--js-runtimes", "bun")
+func (s *YTDLPService) run(ctx context.Context, args []string) ([]byte, error) {
+	var output []byte
+	err := s.pool.Do(ctx, func() error {
+		var runErr error
+		output, runErr = exec.CommandContext(ctx, "yt-dlp", args...).CombinedOutput()
+		return runErr
+	})
+	if err != nil && ctx.Err() != nil {
+		return nil, ctx.Err()
 	}
+	return output, err
+}
 
-	if s.cookiePath != "" {
-		args = append(args, "--cookies", s.cookiePath)
+// GetDownloadURLs reads the direct URLs of the default format selection from
+// the shared extraction: one per requested format when video and audio are
+// separate streams, otherwise the single muxed URL. If cached URLs expire
+// within minValidity (at least urlExpiryMargin), the video is extracted again.
+// A fresh extraction is returned as is, with its expiry, even when it falls
+// short of minValidity, since extracting again would not do better.
+func (s *YTDLPService) GetDownloadURLs(ctx context.Context, url string, minValidity time.Duration) (*models.VideoURL, error) {
+	data, err := s.Extract(ctx, url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract URLs: %w", err)
 	}
 
-	args = append(args, url)
-
-	cmd := exec.CommandContext(ctx, "yt-dlp", args...)
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return nil, fmt.Errorf("failed to extract URLs: %w (output: %s)", err, string(output))
+	minValidity = max(minValidity, urlExpiryMargin)
+	if expiresAt, ok := extractionExpiry(data); ok && time.Until(expiresAt) < minValidity && !freshExtraction(data) {
+		if data, err = s.Refresh(ctx, url); err != nil {
+			return nil, fmt.Errorf("failed to refresh URLs: %w", err)
+		}
 	}
 
-	urls := strings.Split(strings.TrimSpace(string(output)), "\n")
+	var urls []string
+	for _, f := range data.RequestedFormats {
+		if f.URL != "" {
+			urls = append(urls, f.URL)
+		}
+	}
+	if len(urls) == 0 && data.URL != "" {
+		urls = append(urls, data.URL)
+	}
 
 	if len(urls) == 0 {
 		return nil, fmt.Errorf("no URLs found")
@@ -68,30 +117,58 @@ func (s *YTDLPService) GetDownloadURLs(ctx context.Context, url string) (*models
 	result := &models.VideoURL{
 		VideoURL: urls[0],
 	}
+	if expiresAt, ok := extractionExpiry(data); ok {
+		result.ExpiresAt = expiresAt.Unix()
+	}
 
 	if len(urls) > 1 {
 		result.AudioURL = urls[1]
 	}
 
-	s.cache.Set(cacheKey, result, cache.DefaultExpiration)
-
 	return result, nil
 }
 
-func (s *YTDLPService) GetVideoInfo(ctx context.Context, url string) (*models.VideoInfo, error) {
-	cacheKey := "info_" + url
-	if cached, found := s.cache.Get(cacheKey); found {
-		return cached.(*models.VideoInfo), nil
+// Extract returns yt-dlp's full -J output for url. There is one cached
+// extraction per canonical video which /dl, /info and /formats all derive
+// from, and concurrent callers for the same video share a single process.
+func (s *YTDLPService) Extract(ctx context.Context, url string) (*models.YTDLPOutput, error) {
+	key := s.CacheKey(url)
+	var cached models.YTDLPOutput
+	if s.cacheGet(ctx, "json_"+key, &cached) {
+		return &cached, nil
 	}
 
+	ch := s.extractions.DoChan(key, func() (interface{}, error) {
+		// The extraction is shared, so it must not die with whichever
+		// caller happened to start it.
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), extractTimeout)
+		defer cancel()
+		return s.extract(ctx, url)
+	})
+
 	select {
-	case s.semaphore <- struct{}{}:
-		defer func() { <-s.semaphore }()
+	case res := <-ch:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		return res.Val.(*models.YTDLPOutput), nil
 	case <-ctx.Done():
 		return nil, ctx.Err()
 	}
+}
+
+// Refresh drops the cached extraction of url and extracts it again, e.g.
+// because its direct URLs are about to expire.
+func (s *YTDLPService) Refresh(ctx context.Context, url string) (*models.YTDLPOutput, error) {
+	key := "json_" + s.CacheKey(url)
+	if err := s.cache.Delete(ctx, key); err != nil {
+		log.Printf(" Cache delete failed for %s: %v", key, err)
+	}
+	return s.Extract(ctx, url)
+}
 
-	args := []string{"-J", "--no-warnings", "--no-cache-dir"}
+func (s *YTDLPService) extract(ctx context.Context, url string) (*models.YTDLPOutput, error) {
+	args := []string{"-J", "--no-playlist", "--no-warnings", "--no-cache-dir"}
 
 	if path, err := exec.LookPath("bun"); err == nil && path != "" {
 		args = append(args, "--js-runtimes", "bun")
@@ -103,10 +180,9 @@ func (s *YTDLPService) GetVideoInfo(ctx context.Context, url string) (*models.Vi
 
 	args = append(args, url)
 
-	cmd := exec.CommandContext(ctx, "yt-dlp", args...)
-	output, err := cmd.CombinedOutput()
+	output, err := s.run(ctx, args)
 	if err != nil {
-		return nil, fmt.Errorf("failed to extract info: %w (output: %s)", err, string(output))
+		return nil, fmt.Errorf("%w (output: %s)", err, string(output))
 	}
 
 	var data models.YTDLPOutput
@@ -114,37 +190,60 @@ func (s *YTDLPService) GetVideoInfo(ctx context.Context, url string) (*models.Vi
 		return nil, fmt.Errorf("failed to parse JSON: %w", err)
 	}
 
-	info := &models.VideoInfo{
-		ID:          data.ID,
-		Title:       data.Title,
-		Duration:    int(data.Duration),
-		Thumbnail:   data.Thumbnail,
-		Description: data.Description,
-		Uploader:    data.Uploader,
-		ViewCount:   data.ViewCount,
-		UploadDate:  data.UploadDate,
+	// Cache under the canonical key so other URLs of this video hit it too.
+	s.rememberID(ctx, url, data.ExtractorKey, data.ID)
+
+	// Direct URLs are signed with their own expiry, which may be sooner than
+	// extractionTTL; never serve them from cache past it.
+	ttl := extractionTTL
+	if expiresAt, ok := extractionExpiry(&data); ok {
+		ttl = min(ttl, time.Until(expiresAt)-urlExpiryMargin)
+	}
+	if ttl > 0 {
+		s.cacheSet(ctx, "json_"+s.CacheKey(url), &data, ttl)
 	}
 
-	s.cache.Set(cacheKey, info, cache.DefaultExpiration)
+	return &data, nil
+}
 
-	return info, nil
+// CacheKey resolves url to the canonical key used by every cache: known
+// sites via NormalizeURL, other URLs via the extractor_key and id remembered
+// from an earlier extraction. Unknown URLs are their own key.
+func (s *YTDLPService) CacheKey(url string) string {
+	if key, ok := NormalizeURL(url); ok {
+		return key
+	}
+	var cached string
+	if s.cacheGet(context.Background(), "id_"+url, &cached) {
+		return cached
+	}
+	return url
 }
 
-func (s *YTDLPService) GetFormats(ctx context.Context, url string) (*models.FormatsResponse, error) {
-	cacheKey := "fmt_" + url
-	if cached, found := s.cache.Get(cacheKey); found {
-		return cached.(*models.FormatsResponse), nil
+// rememberID records the canonical key yt-dlp reported for url.
+func (s *YTDLPService) rememberID(ctx context.Context, url, extractorKey, id string) {
+	if extractorKey == "" || id == "" {
+		return
 	}
+	s.cacheSet(ctx, "id_"+url, strings.ToLower(extractorKey)+":"+id, canonicalIDTTL)
+}
 
-	select {
-	case s.semaphore <- struct{}{}:
-		defer func() { <-s.semaphore }()
-	case <-ctx.Done():
-		return nil, ctx.Err()
+// CanonicalID identifies the video behind url as "extractor:id", so that
+// different URLs of the same video map to the same key. Well-known URLs are
+// resolved by NormalizeURL; anything else asks yt-dlp for its
+// extractor_key and id.
+func (s *YTDLPService) CanonicalID(ctx context.Context, url string) (string, error) {
+	if key, ok := NormalizeURL(url); ok {
+		return key, nil
+	}
+
+	var cached string
+	if s.cacheGet(ctx, "id_"+url, &cached) {
+		return cached, nil
 	}
 
 	args := []string{
-		"-J",
+		"--print", "%(extractor_key)s:%(id)s",
 		"--no-playlist",
 		"--no-warnings",
 		"--no-cache-dir",
@@ -160,70 +259,135 @@ func (s *YTDLPService) GetFormats(ctx context.Context, url string) (*models.Form
 
 	args = append(args, url)
 
-	cmd := exec.CommandContext(ctx, "yt-dlp", args...)
-	output, err := cmd.CombinedOutput()
+	output, err := s.run(ctx, args)
 	if err != nil {
-		return nil, fmt.Errorf("failed to extract formats: %w (output: %s)", err, string(output))
+		return "", fmt.Errorf("failed to identify video: %w (output: %s)", err, string(output))
 	}
 
-	var data models.YTDLPOutput
-	if err := json.Unmarshal(output, &data); err != nil {
-		return nil, fmt.Errorf("failed to parse JSON: %w", err)
+	line, _, _ := strings.Cut(strings.TrimSpace(string(output)), "\n")
+	extractor, id, ok := strings.Cut(line, ":")
+	if !ok || extractor == "" || extractor == "NA" || id == "" || id == "NA" {
+		return "", fmt.Errorf("unexpected identification output: %q", line)
 	}
+	s.rememberID(ctx, url, extractor, id)
 
-	response := &models.FormatsResponse{
-		VideoID: data.ID,
-		Formats: data.Formats,
+	return s.CacheKey(url), nil
+}
+
+func (s *YTDLPService) GetVideoInfo(ctx context.Context, url string) (*models.VideoInfo, error) {
+	data, err := s.Extract(ctx, url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract info: %w", err)
 	}
 
-	s.cache.Set(cacheKey, response, cache.DefaultExpiration)
+	info := &models.VideoInfo{
+		ID:          data.ID,
+		Title:       data.Title,
+		Duration:    int(data.Duration),
+		Thumbnail:   data.Thumbnail,
+		Description: data.Description,
+		Uploader:    data.Uploader,
+		ViewCount:   data.ViewCount,
+		UploadDate:  data.UploadDate,
+
+		Channel:      data.Channel,
+		ChannelID:    data.ChannelID,
+		ChannelURL:   data.ChannelURL,
+		LikeCount:    data.LikeCount,
+		CommentCount: data.CommentCount,
+		Tags:         data.Tags,
+		Categories:   data.Categories,
+		Chapters:     data.Chapters,
+		AgeLimit:     data.AgeLimit,
+		Availability: data.Availability,
+		LiveStatus:   data.LiveStatus,
+		WebpageURL:   data.WebpageURL,
+		Extractor:    data.Extractor,
+		AspectRatio:  data.AspectRatio,
+		Thumbnails:   data.Thumbnails,
+	}
 
-	return response, nil
+	return info, nil
 }
 
-func (s *YTDLPService) DownloadToFile(ctx context.Context, url, outputPath, quality, formatType, containerFormat string) error {
-	select {
-	case s.semaphore <- struct{}{}:
-		defer func() { <-s.semaphore }()
-	case <-ctx.Done():
-		return ctx.Err()
+// GetFormats lists the formats of url that match filter.
+func (s *YTDLPService) GetFormats(ctx context.Context, url string, filter FormatFilter) (*models.FormatsResponse, error) {
+	data, err := s.Extract(ctx, url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract formats: %w", err)
 	}
 
-	var args []string
+	response := &models.FormatsResponse{
+		VideoID: data.ID,
+		Formats: filter.Apply(data.Formats),
+	}
 
-	if formatType == "audio" {
-		args = []string{
-			"-f", "bestaudio/best",
-			"--extract-audio",
-			"--audio-format", "mp3",
-			"--audio-quality", "0",
-			"--no-playlist",
-			"--no-warnings",
-			"--no-cache-dir",
-			"-o", outputPath,
-		}
-	} else {
-		format := "bestvideo+bestaudio/best"
-		if quality == "720p" {
-			format = "bestvideo[height<=720]+bestaudio/best[height<=720]"
-		} else if quality == "1080p" {
-			format = "bestvideo[height<=1080]+bestaudio/best[height<=1080]"
-		}
+	return response, nil
+}
 
+// progressTemplate makes yt-dlp print one machine-readable line per progress
+// update; see parseProgressLine.
+const progressTemplate = "download:" + progressPrefix +
+	"%(progress.downloaded_bytes)s|%(progress.total_bytes)s|%(progress.total_bytes_estimate)s|" +
+	"%(progress.speed)s|%(progress.eta)s|%(info.vcodec)s"
+
+const progressPrefix = "[progress] "
+
+// DownloadToFile downloads req to outputBase plus the extension of whatever
+// yt-dlp produced, and returns that path. onProgress, if not nil, is called
+// from the download goroutine for every progress update.
+func (s *YTDLPService) DownloadToFile(ctx context.Context, req models.MergeRequest, outputBase string, onProgress func(models.Progress)) (string, error) {
+	args := []string{"-f", formatSelector(req)}
+	source := []string{req.URL}
+	var mismatch map[string]string
+
+	if req.Type == "audio" {
+		args = append(args, audioArgs(req)...)
+		args = append(args, embedArgs(req)...)
+	} else {
 		if _, err := exec.LookPath("ffmpeg"); err != nil {
-			return fmt.Errorf("ffmpeg not found: explicit merge requested but ffmpeg is missing in PATH")
+			return "", fmt.Errorf("ffmpeg not found: explicit merge requested but ffmpeg is missing in PATH")
 		}
 
-		args = []string{
-			"-f", format,
-			"--merge-output-format", containerFormat,
-			"--no-playlist",
-			"--no-warnings",
-			"--no-cache-dir",
-			"-o", outputPath,
+		mergeFormat := req.Format
+		if checksCodecs(req) {
+			// Check the picked streams before downloading them, then replay
+			// the same extraction so yt-dlp does not run it twice.
+			info, formats, err := s.resolveFormats(ctx, req)
+			if err != nil {
+				return "", err
+			}
+			mismatch = codecMismatch(req, formats)
+			if len(mismatch) > 0 {
+				if req.Conversion != conversionTranscode {
+					return "", codecError(req, mismatch)
+				}
+				mergeFormat = "mkv"
+			}
+
+			infoPath := outputBase + ".info.json"
+			if err := os.WriteFile(infoPath, info, 0644); err != nil {
+				return "", err
+			}
+			source = []string{"--load-info-json", infoPath}
 		}
+		args = append(args, "--merge-output-format", mergeFormat)
+		args = append(args, subtitleArgs(req)...)
 	}
 
+	clip, err := s.clipArgs(ctx, req, outputBase)
+	if err != nil {
+		return "", err
+	}
+	args = append(args, clip...)
+
+	args = append(args,
+		"--no-playlist",
+		"--no-warnings",
+		"--no-cache-dir",
+		"-o", outputBase+".%(ext)s",
+	)
+
 	if path, err := exec.LookPath("bun"); err == nil && path != "" {
 		args = append(args, "--js-runtimes", "bun")
 	}
@@ -232,15 +396,139 @@ func (s *YTDLPService) DownloadToFile(ctx context.Context, url, outputPath, qual
 		args = append(args, "--cookies", s.cookiePath)
 	}
 
-	args = append(args, url)
+	args = append(args, "--newline", "--progress-template", progressTemplate)
+	args = append(args, source...)
 
 	log.Printf("Executing yt-dlp with args: %v", args)
 
-	cmd := exec.CommandContext(ctx, "yt-dlp", args...)
-	output, err := cmd.CombinedOutput()
+	err = s.runLines(ctx, args, func(line string) {
+		if onProgress == nil {
+			return
+		}
+		switch {
+		case strings.HasPrefix(line, progressPrefix):
+			if p, ok := parseProgressLine(line, req.Type); ok {
+				onProgress(p)
+			}
+		case strings.HasPrefix(line, "[Merger]"):
+			onProgress(models.Progress{Phase: models.PhaseMerge})
+		case strings.HasPrefix(line, "[ExtractAudio]"):
+			onProgress(models.Progress{Phase: models.PhasePostprocess})
+		}
+	})
 	if err != nil {
-		return fmt.Errorf("failed to download: %w (output: %s)", err, string(output))
+		return "", fmt.Errorf("failed to download: %w", err)
 	}
 
-	return nil
+	output, err := findOutput(outputBase)
+	if err != nil {
+		return "", err
+	}
+	if len(mismatch) > 0 {
+		if onProgress != nil {
+			onProgress(models.Progress{Phase: models.PhasePostprocess})
+		}
+		if output, err = s.transcode(ctx, output, req, mismatch); err != nil {
+			return "", err
+		}
+	}
+	if req.Tags != nil {
+		if onProgress != nil {
+			onProgress(models.Progress{Phase: models.PhasePostprocess})
+		}
+		if err := s.applyTags(ctx, output, req.Tags); err != nil {
+			return "", err
+		}
+	}
+	return output, nil
+}
+
+// findOutput returns the file yt-dlp left at outputBase.*, ignoring partial
+// downloads and the per-format intermediates of a merge.
+func findOutput(outputBase string) (string, error) {
+	matches, err := filepath.Glob(outputBase + ".*")
+	if err != nil {
+		return "", err
+	}
+	for _, match := range matches {
+		name := strings.TrimPrefix(match, outputBase)
+		if strings.HasSuffix(name, ".part") || strings.HasSuffix(name, ".ytdl") ||
+			strings.HasSuffix(name, ".temp") || strings.HasSuffix(name, ".json") ||
+			intermediatePattern.MatchString(name) ||
+			thumbnailPattern.MatchString(name) ||
+			subtitleFilePattern.MatchString(name) ||
+			chapterFilePattern.MatchString(name) {
+			continue
+		}
+		return match, nil
+	}
+	return "", fmt.Errorf("yt-dlp produced no output file")
+}
+
+var (
+	intermediatePattern = regexp.MustCompile(`^\.f[0-9A-Za-z_-]+\.`)
+	thumbnailPattern    = regexp.MustCompile(`\.(jpg|jpeg|png|webp)$`)
+)
+
+// runLines runs yt-dlp on the pool, passing each stdout line to onLine as it
+// is printed. Stderr is collected and included in the returned error.
+func (s *YTDLPService) runLines(ctx context.Context, args []string, onLine func(string)) error {
+	return s.pool.Do(ctx, func() error {
+		var stderr bytes.Buffer
+		cmd := exec.CommandContext(ctx, "yt-dlp", args...)
+		cmd.Stderr = &stderr
+
+		stdout, err := cmd.StdoutPipe()
+		if err != nil {
+			return err
+		}
+		if err := cmd.Start(); err != nil {
+			return err
+		}
+
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			onLine(scanner.Text())
+		}
+
+		if err := cmd.Wait(); err != nil {
+			return fmt.Errorf("%w (output: %s)", err, stderr.String())
+		}
+		return nil
+	})
+}
+
+func parseProgressLine(line, formatType string) (models.Progress, bool) {
+	fields := strings.Split(strings.TrimPrefix(line, progressPrefix), "|")
+	if len(fields) != 6 {
+		return models.Progress{}, false
+	}
+
+	p := models.Progress{
+		Phase:           models.PhaseDownloadVideo,
+		DownloadedBytes: int64(parseProgressNumber(fields[0])),
+		TotalBytes:      int64(parseProgressNumber(fields[1])),
+		Speed:           parseProgressNumber(fields[3]),
+		ETA:             int(parseProgressNumber(fields[4])),
+	}
+	if p.TotalBytes == 0 {
+		p.TotalBytes = int64(parseProgressNumber(fields[2]))
+	}
+	if p.TotalBytes > 0 {
+		p.Percent = float64(p.DownloadedBytes) / float64(p.TotalBytes) * 100
+	}
+	if formatType == "audio" || fields[5] == "none" {
+		p.Phase = models.PhaseDownloadAudio
+	}
+
+	return p, true
+}
+
+// parseProgressNumber returns 0 for fields yt-dlp reports as "NA".
+func parseProgressNumber(field string) float64 {
+	v, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+	if err != nil {
+		return 0
+	}
+	return v
 }
@@ -0,0 +1,80 @@
+package store
+
+import (
+	"sync"
+	"time"
+
+	"github.com/patrickmn/go-cache"
+	"github.com/pavelc4/ytdpl-api-go/internal/models"
+)
+
+// MemoryStore keeps everything in process memory and loses it on restart.
+type MemoryStore struct {
+	mu      sync.RWMutex
+	jobs    map[string]models.Job
+	results *cache.Cache
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		jobs:    make(map[string]models.Job),
+		results: cache.New(time.Hour, 2*time.Hour),
+	}
+}
+
+func (s *MemoryStore) SaveJob(job *models.Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.jobs[job.ID] = *job
+	return nil
+}
+
+func (s *MemoryStore) GetJob(id string) (*models.Job, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	job, ok := s.jobs[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &job, nil
+}
+
+func (s *MemoryStore) ListJobs() ([]*models.Job, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	jobs := make([]*models.Job, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		job := job
+		jobs = append(jobs, &job)
+	}
+	return jobs, nil
+}
+
+func (s *MemoryStore) DeleteJob(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.jobs, id)
+	return nil
+}
+
+func (s *MemoryStore) SaveResult(key string, result *models.MergeResult, ttl time.Duration) error {
+	s.results.Set(key, *result, ttl)
+	return nil
+}
+
+func (s *MemoryStore) GetResult(key string) (*models.MergeResult, error) {
+	cached, found := s.results.Get(key)
+	if !found {
+		return nil, ErrNotFound
+	}
+	result := cached.(models.MergeResult)
+	return &result, nil
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
@@ -0,0 +1,32 @@
+package store
+
+import (
+	"errors"
+	"time"
+
+	"github.com/pavelc4/ytdpl-api-go/internal/models"
+)
+
+var ErrNotFound = errors.New("not found")
+
+// Store persists merge jobs and finished upload results.
+type Store interface {
+	SaveJob(job *models.Job) error
+	GetJob(id string) (*models.Job, error)
+	ListJobs() ([]*models.Job, error)
+	DeleteJob(id string) error
+
+	SaveResult(key string, result *models.MergeResult, ttl time.Duration) error
+	// GetResult returns ErrNotFound when key is missing or expired.
+	GetResult(key string) (*models.MergeResult, error)
+
+	Close() error
+}
+
+// New opens a BoltDB store at path, or an in-memory store when path is empty.
+func New(path string) (Store, error) {
+	if path == "" {
+		return NewMemoryStore(), nil
+	}
+	return NewBoltStore(path)
+}